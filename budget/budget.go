@@ -0,0 +1,142 @@
+package budget
+
+import (
+	"time"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+//DefaultThreshold - share of the budget after which a budget is flagged
+const DefaultThreshold = 0.8
+
+/*Budget - monthly spending limit of a group, or of one category in the group*/
+type Budget struct {
+	ID      int `json:"id"`
+	GroupID int `json:"group_id"`
+	//CategoryID - category or parent category, 0 for all expenses of the group
+	CategoryID   int    `json:"category_id"`
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currency_code"`
+	//Threshold - share of Amount between 0 and 1 which raises the alert
+	Threshold float64 `json:"threshold"`
+}
+
+/*Status - consumption of a budget in the period containing a date*/
+type Status struct {
+	Budget      Budget        `json:"budget"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	Spent       expense.Money `json:"spent"`
+	Remaining   expense.Money `json:"remaining"`
+	//Projected - month end spend if spending continues at the rate so far
+	Projected        expense.Money `json:"projected"`
+	SpentShare       float64       `json:"spent_share"`
+	ThresholdReached bool          `json:"threshold_reached"`
+	OverBudget       bool          `json:"over_budget"`
+	ProjectedOver    bool          `json:"projected_over"`
+	Expenses         int           `json:"expenses"`
+	//SkippedExpenses - expenses in another currency which could not be converted
+	SkippedExpenses int `json:"skipped_expenses"`
+}
+
+/*Validate - check amount, currency and threshold, the group and category are checked by the caller*/
+func (budget *Budget) Validate() error {
+	if budget.GroupID == 0 {
+		return errors.New("group_id is required")
+	}
+	if !expense.IsCurrencyCode(budget.CurrencyCode) {
+		return errors.New("currency_code must be a three letter ISO currency code")
+	}
+	amount, err := expense.ParseMoney(budget.Amount, budget.CurrencyCode)
+	if err != nil {
+		return errors.Wrap(err, "invalid amount")
+	}
+	if amount.Minor <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+	if budget.Threshold < 0 || budget.Threshold > 1 {
+		return errors.New("threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+/*Period - calendar month containing date in date's time zone*/
+func Period(date time.Time) (time.Time, time.Time) {
+	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+/*Applies - whether individualExpense counts against budget, parents maps subcategories to their category*/
+func (budget *Budget) Applies(individualExpense expense.Expense, parents map[int]int) bool {
+	if individualExpense.GroupID != budget.GroupID || individualExpense.Payment || !individualExpense.DeletedAt.IsZero() {
+		return false
+	}
+	if budget.CategoryID == 0 {
+		return true
+	}
+	categoryID := individualExpense.Category.ID
+	return categoryID == budget.CategoryID || parents[categoryID] == budget.CategoryID
+}
+
+/*Compute - status of budget on date from expenses already converted to the budget currency where possible*/
+func Compute(budget Budget, expenses []expense.Expense, parents map[int]int, date time.Time) (Status, error) {
+	amount, err := expense.ParseMoney(budget.Amount, budget.CurrencyCode)
+	if err != nil {
+		return Status{}, err
+	}
+	threshold := budget.Threshold
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+
+	start, end := Period(date)
+	status := Status{
+		Budget:      budget,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Spent:       expense.Money{Currency: budget.CurrencyCode},
+	}
+	for _, individualExpense := range expenses {
+		if individualExpense.Date.Before(start) || !individualExpense.Date.Before(end) || !budget.Applies(individualExpense, parents) {
+			continue
+		}
+		if individualExpense.CurrencyCode != budget.CurrencyCode {
+			status.SkippedExpenses++
+			continue
+		}
+		cost, err := expense.ParseMoney(individualExpense.Cost, individualExpense.CurrencyCode)
+		if err != nil {
+			return Status{}, err
+		}
+		status.Spent.Minor += cost.Minor
+		status.Expenses++
+	}
+
+	status.Remaining = expense.Money{Minor: amount.Minor - status.Spent.Minor, Currency: budget.CurrencyCode}
+	status.Projected = project(status.Spent, start, end, date)
+	status.SpentShare = float64(status.Spent.Minor) / float64(amount.Minor)
+	status.ThresholdReached = status.SpentShare >= threshold
+	status.OverBudget = status.Spent.Minor > amount.Minor
+	status.ProjectedOver = status.Projected.Minor > amount.Minor
+	return status, nil
+}
+
+/*project - spent scaled from the days elapsed on date to the whole period*/
+func project(spent expense.Money, start time.Time, end time.Time, date time.Time) expense.Money {
+	days := calendarDays(start, end)
+	elapsed := calendarDays(start, date) + 1
+	if elapsed >= days {
+		return spent
+	}
+	//round half up, spent is never negative
+	return expense.Money{Minor: (spent.Minor*days + elapsed/2) / elapsed, Currency: spent.Currency}
+}
+
+/*calendarDays - dates from from up to to, a day with a dst change is still one day*/
+func calendarDays(from time.Time, to time.Time) int64 {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int64(toDay.Sub(fromDay) / (24 * time.Hour))
+}
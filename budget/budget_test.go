@@ -0,0 +1,35 @@
+package budget
+
+import (
+	"testing"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+)
+
+func TestProjectCountsCalendarDays(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name string
+		date time.Time
+		want int64
+	}{
+		//march has a day of 23 hours
+		{"dst starts", time.Date(2019, 3, 15, 12, 0, 0, 0, newYork), 3100},
+		//november has a day of 25 hours
+		{"dst ends", time.Date(2019, 11, 15, 12, 0, 0, 0, newYork), 3000},
+		{"utc", time.Date(2019, 3, 15, 12, 0, 0, 0, time.UTC), 3100},
+		{"first day", time.Date(2019, 3, 1, 0, 0, 0, 0, newYork), 46500},
+		{"last day", time.Date(2019, 3, 31, 23, 0, 0, 0, newYork), 1500},
+	}
+	for _, test := range tests {
+		start, end := Period(test.date)
+		projected := project(expense.Money{Minor: 1500, Currency: "USD"}, start, end, test.date)
+		if projected.Minor != test.want {
+			t.Errorf("%s: projected %d, want %d", test.name, projected.Minor, test.want)
+		}
+	}
+}
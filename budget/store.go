@@ -0,0 +1,139 @@
+package budget
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+/*Store - budgets of every user, kept in memory and written to path when it is set*/
+type Store struct {
+	mutex   sync.Mutex
+	path    string
+	budgets map[string][]Budget
+}
+
+/*NewMemoryStore - store which loses its budgets on restart*/
+func NewMemoryStore() *Store {
+	return &Store{budgets: make(map[string][]Budget)}
+}
+
+/*NewStore - store backed by the json file at path, in memory only when path is empty*/
+func NewStore(path string) (*Store, error) {
+	store := NewMemoryStore()
+	if path == "" {
+		return store, nil
+	}
+	store.path = path
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading budgets")
+	}
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, &store.budgets); err != nil {
+			return nil, errors.Wrap(err, "error parsing budgets")
+		}
+	}
+	return store, nil
+}
+
+/*List - budgets of user, only of groupID when it is not 0*/
+func (store *Store) List(user string, groupID int) []Budget {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	budgets := make([]Budget, 0)
+	for _, budget := range store.budgets[user] {
+		if groupID == 0 || budget.GroupID == groupID {
+			budgets = append(budgets, budget)
+		}
+	}
+	return budgets
+}
+
+/*Add - save budget with a new id*/
+func (store *Store) Add(user string, budget Budget) (Budget, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	budget.ID = 1
+	for _, existing := range store.budgets[user] {
+		if existing.ID >= budget.ID {
+			budget.ID = existing.ID + 1
+		}
+	}
+	previous := store.budgets[user]
+	store.budgets[user] = append(append([]Budget(nil), previous...), budget)
+	if err := store.save(); err != nil {
+		store.budgets[user] = previous
+		return Budget{}, err
+	}
+	return budget, nil
+}
+
+/*Update - replace the budget with budget.ID, false when user has no such budget*/
+func (store *Store) Update(user string, budget Budget) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	previous := store.budgets[user]
+	for index, existing := range previous {
+		if existing.ID != budget.ID {
+			continue
+		}
+		updated := append([]Budget(nil), previous...)
+		updated[index] = budget
+		store.budgets[user] = updated
+		if err := store.save(); err != nil {
+			store.budgets[user] = previous
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+/*Delete - remove budget id of user, false when there is no such budget*/
+func (store *Store) Delete(user string, id int) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	previous := store.budgets[user]
+	for index, existing := range previous {
+		if existing.ID != id {
+			continue
+		}
+		remaining := append(append([]Budget(nil), previous[:index]...), previous[index+1:]...)
+		store.budgets[user] = remaining
+		if err := store.save(); err != nil {
+			store.budgets[user] = previous
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+/*save - write all budgets through a temp file so readers never see a partial file*/
+func (store *Store) save() error {
+	if store.path == "" {
+		return nil
+	}
+	contents, err := json.Marshal(store.budgets)
+	if err != nil {
+		return errors.Wrap(err, "error encoding budgets")
+	}
+
+	tempPath := store.path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, contents, 0600); err != nil {
+		return errors.Wrap(err, "error writing budgets")
+	}
+	return errors.Wrap(os.Rename(tempPath, store.path), "error writing budgets")
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*Stats - counters of a cache since it was created*/
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+}
+
+/*Cache - values expiring ttl after they were set, the least recently used entry is evicted beyond maxEntries*/
+type Cache struct {
+	mutex      sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	//order - most recently used first
+	order *list.List
+	stats Stats
+	now   func() time.Time
+}
+
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+/*New - empty cache*/
+func New(ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+/*TTL - how long values stay in the cache*/
+func (cache *Cache) TTL() time.Duration {
+	return cache.ttl
+}
+
+/*Get - value for key unless it is missing or expired*/
+func (cache *Cache) Get(key string) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		cache.stats.Misses++
+		return nil, false
+	}
+	cached := element.Value.(*entry)
+	if !cache.now().Before(cached.expiresAt) {
+		cache.remove(element)
+		cache.stats.Misses++
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	cache.stats.Hits++
+	return cached.value, true
+}
+
+/*Set - store value for key, evicting the least recently used entry when the cache is full*/
+func (cache *Cache) Set(key string, value interface{}) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	expiresAt := cache.now().Add(cache.ttl)
+	if element, ok := cache.entries[key]; ok {
+		element.Value = &entry{key: key, value: value, expiresAt: expiresAt}
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for cache.maxEntries > 0 && cache.order.Len() > cache.maxEntries {
+		cache.remove(cache.order.Back())
+		cache.stats.Evictions++
+	}
+}
+
+/*DeletePrefix - drop every entry whose key starts with prefix*/
+func (cache *Cache) DeletePrefix(prefix string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for key, element := range cache.entries {
+		if strings.HasPrefix(key, prefix) {
+			cache.remove(element)
+		}
+	}
+}
+
+/*Stats - current counters*/
+func (cache *Cache) Stats() Stats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	stats := cache.stats
+	stats.Entries = cache.order.Len()
+	return stats
+}
+
+func (cache *Cache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"splitwiseAngularAPI/expense"
+)
+
+/*GetGroupBalances - member balances per currency and debts, ?simplified=true|false overrides the group default*/
+func GetGroupBalances(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	q := r.URL.Query()
+	groupID, err := strconv.Atoi(q.Get("groupID"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
+		return
+	}
+
+	group, err := requestClient(r).GetGroup(groupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	simplified := group.SimplifyByDefault
+	if value := q.Get("simplified"); value != "" {
+		simplified, err = strconv.ParseBool(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "simplified must be true or false")
+			return
+		}
+	}
+
+	//send response
+	contentJSON, err := json.Marshal(extractBalances(group, simplified))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
+
+func extractBalances(group *expense.Group, simplified bool) expense.GroupBalances {
+	balances := expense.GroupBalances{
+		GroupID:    group.ID,
+		Members:    make([]expense.Members, 0, len(group.Members)),
+		Simplified: simplified,
+		Debts:      group.OriginalDebts,
+	}
+	if simplified {
+		balances.Debts = group.SimplifiedDebts
+	}
+	if balances.Debts == nil {
+		balances.Debts = make([]expense.Debt, 0)
+	}
+
+	for _, member := range group.Members {
+		if member.Balance == nil {
+			member.Balance = make([]expense.Balance, 0)
+		}
+		balances.Members = append(balances.Members, member)
+	}
+	return balances
+}
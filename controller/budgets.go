@@ -0,0 +1,287 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/budget"
+	"splitwiseAngularAPI/daterange"
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/gorilla/mux"
+)
+
+//budgets - monthly budgets of every user
+var budgets = budget.NewMemoryStore()
+
+/*budgetStatus - response of GetBudgetStatus*/
+type budgetStatus struct {
+	GroupID  int             `json:"group_id"`
+	Date     time.Time       `json:"date"`
+	Statuses []budget.Status `json:"budgets"`
+}
+
+/*GetBudgets - budgets of the current user, ?groupID= for one group*/
+func GetBudgets(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	groupID := 0
+	if value := r.URL.Query().Get("groupID"); value != "" {
+		var err error
+		groupID, err = strconv.Atoi(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid groupID")
+			return
+		}
+	}
+
+	contentJSON, err := json.Marshal(map[string][]budget.Budget{"budgets": budgets.List(sessionVals.user, groupID)})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
+
+/*CreateBudget - add a monthly budget for a group or a category in it*/
+func CreateBudget(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	newBudget, ok := budgetFromRequest(w, r, requestClient(r))
+	if !ok {
+		return
+	}
+	newBudget, err := budgets.Add(sessionVals.user, newBudget)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error saving budget")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newBudget)
+}
+
+/*UpdateBudget - replace budget {id}*/
+func UpdateBudget(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	budgetID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid budget id")
+		return
+	}
+	updatedBudget, ok := budgetFromRequest(w, r, requestClient(r))
+	if !ok {
+		return
+	}
+	updatedBudget.ID = budgetID
+
+	updated, err := budgets.Update(sessionVals.user, updatedBudget)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error saving budget")
+		return
+	}
+	if !updated {
+		writeJSONError(w, http.StatusNotFound, "budget not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(updatedBudget)
+}
+
+/*DeleteBudget - remove budget {id}*/
+func DeleteBudget(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	budgetID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid budget id")
+		return
+	}
+	deleted, err := budgets.Delete(sessionVals.user, budgetID)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error deleting budget")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "budget not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+/*GetBudgetStatus - spent, projected month end spend and alerts of the group's budgets, ?date= picks another month*/
+func GetBudgetStatus(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	defer r.Body.Close()
+	q := r.URL.Query()
+	groupID, err := strconv.Atoi(q.Get("groupID"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
+		return
+	}
+	location, err := daterange.Location(q, sessionLocation(sessionVals))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	date := time.Now().In(location)
+	if value := q.Get("date"); value != "" {
+		date, err = time.ParseInLocation("2006-01-02", value, location)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "date must be a 2006-01-02 date")
+			return
+		}
+	}
+
+	client := requestClient(r)
+	categories, err := client.GetCategories()
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	parents := make(map[int]int)
+	for _, category := range categories {
+		for _, subcategory := range category.Subcategories {
+			parents[subcategory.ID] = category.ID
+		}
+	}
+
+	start, end := budget.Period(date)
+	expenses, err := mirroredExpenses(w, r, splitwise.ExpensesQuery{
+		GroupID:     groupID,
+		DatedAfter:  start,
+		DatedBefore: end,
+	})
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	status := budgetStatus{GroupID: groupID, Date: date, Statuses: make([]budget.Status, 0)}
+	for _, groupBudget := range budgets.List(sessionVals.user, groupID) {
+		groupStatus, err := budget.Compute(groupBudget, expensesIn(expenses, groupBudget.CurrencyCode), parents, date)
+		if err != nil {
+			Trace.Println(err)
+			writeJSONError(w, http.StatusBadGateway, "splitwise returned an invalid amount")
+			return
+		}
+		status.Statuses = append(status.Statuses, groupStatus)
+	}
+
+	//send response
+	contentJSON, err := json.Marshal(status)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
+
+/*expensesIn - expenses converted to currencyCode where exchange rates allow, others are left as they are*/
+func expensesIn(expenses []expense.Expense, currencyCode string) []expense.Expense {
+	if exchangeRates == nil {
+		return expenses
+	}
+	converted := make([]expense.Expense, 0, len(expenses))
+	for _, individualExpense := range expenses {
+		if individualExpense.CurrencyCode != currencyCode {
+			if convertedExpense, _, err := exchangeRates.ConvertExpense(individualExpense, currencyCode); err == nil {
+				individualExpense = convertedExpense
+			}
+		}
+		converted = append(converted, individualExpense)
+	}
+	return converted
+}
+
+/*budgetFromRequest - decode and validate a budget from the body, writes the error response when it is invalid*/
+func budgetFromRequest(w http.ResponseWriter, r *http.Request, client *splitwise.Client) (budget.Budget, bool) {
+	var requestBudget budget.Budget
+	err := json.NewDecoder(r.Body).Decode(&requestBudget)
+	defer r.Body.Close()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid budget: "+err.Error())
+		return budget.Budget{}, false
+	}
+	requestBudget.CurrencyCode = strings.ToUpper(requestBudget.CurrencyCode)
+	if err := requestBudget.Validate(); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return budget.Budget{}, false
+	}
+	if requestBudget.Threshold == 0 {
+		requestBudget.Threshold = budget.DefaultThreshold
+	}
+
+	group, categories, err := loadGroupAndCategories(client, requestBudget.GroupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return budget.Budget{}, false
+	}
+	fieldErrors := make(map[string][]string)
+	if group == nil {
+		fieldErrors["group_id"] = []string{"unknown group"}
+	}
+	if requestBudget.CategoryID != 0 && !expense.CategoryExists(categories, requestBudget.CategoryID) {
+		fieldErrors["category_id"] = []string{"unknown category"}
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, fieldErrors)
+		return budget.Budget{}, false
+	}
+	return requestBudget, true
+}
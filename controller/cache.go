@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/cache"
+)
+
+//cacheMaxEntries - bound of each response cache, the least recently used entry goes first
+const cacheMaxEntries = 1000
+
+//groupCache - groups hold balances so they are kept briefly and dropped when the user changes an expense
+var groupCache = cache.New(5*time.Minute, cacheMaxEntries)
+
+//categoryCache - splitwise categories almost never change
+var categoryCache = cache.New(time.Hour, cacheMaxEntries)
+
+/*writeCacheable - write contentJSON with an ETag, or 304 when the client already has it*/
+func writeCacheable(w http.ResponseWriter, r *http.Request, contentJSON []byte, maxAge time.Duration) {
+	sum := sha256.Sum256(contentJSON)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	//the angular client has to read the ETag to send it back
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match")
+	w.Header().Add("Access-Control-Expose-Headers", "ETag")
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	w.Header().Set("Vary", "Cookie")
+	if etagMatches(strings.Join(r.Header.Values("If-None-Match"), ","), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(contentJSON)
+}
+
+/*etagMatches - whether the If-None-Match list names etag or is *, weak tags match their strong form as RFC 7232 asks for If-None-Match*/
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate != "" && strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+/*GetCacheStats - hit and miss counters of the response caches*/
+func GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	contentJSON, err := json.Marshal(map[string]cache.Stats{
+		"groups":     groupCache.Stats(),
+		"categories": categoryCache.Stats(),
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
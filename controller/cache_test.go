@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteCacheable(t *testing.T) {
+	contentJSON := []byte(`[{"id":100}]`)
+	first := httptest.NewRecorder()
+	writeCacheable(first, httptest.NewRequest("GET", "/getGroups", nil), contentJSON, 0)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first response = %d with ETag %q", first.Code, etag)
+	}
+	if exposed := first.Header().Values("Access-Control-Expose-Headers"); !strings.Contains(strings.Join(exposed, ","), "ETag") {
+		t.Errorf("ETag is not exposed to the angular client: %v", exposed)
+	}
+	if allowed := first.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(allowed, "If-None-Match") {
+		t.Errorf("If-None-Match is not an allowed header: %q", allowed)
+	}
+
+	tests := []struct {
+		ifNoneMatch []string
+		status      int
+	}{
+		{[]string{etag}, http.StatusNotModified},
+		{[]string{"W/" + etag}, http.StatusNotModified},
+		{[]string{`"stale", ` + etag}, http.StatusNotModified},
+		{[]string{`"stale"`, etag}, http.StatusNotModified},
+		{[]string{"*"}, http.StatusNotModified},
+		{[]string{`"stale"`}, http.StatusOK},
+		{[]string{""}, http.StatusOK},
+		{nil, http.StatusOK},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/getGroups", nil)
+		for _, value := range test.ifNoneMatch {
+			request.Header.Add("If-None-Match", value)
+		}
+		recorder := httptest.NewRecorder()
+		writeCacheable(recorder, request, contentJSON, 0)
+		if recorder.Code != test.status {
+			t.Errorf("If-None-Match %q answered %d, want %d", test.ifNoneMatch, recorder.Code, test.status)
+		}
+		if recorder.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %q: ETag %q, want %q", test.ifNoneMatch, recorder.Header().Get("ETag"), etag)
+		}
+	}
+}
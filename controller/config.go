@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/pkg/errors"
+)
+
+//envPrefix - prefix of the env vars configuring the api, flags are named after the env var without it
+const envPrefix = "SPLITWISE_"
+
+/*ConfigErrors - every problem found in the configuration, reported together at startup*/
+type ConfigErrors []string
+
+func (configErrors ConfigErrors) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(configErrors, "\n  - ")
+}
+
+/*defaultConfiguration - values used for anything the file, env vars and flags leave out*/
+func defaultConfiguration() *Configuration {
+	return &Configuration{
+		RequestTokenURL:  "https://secure.splitwise.com/oauth/request_token",
+		AuthorizeURL:     "https://secure.splitwise.com/oauth/authorize",
+		AccessTokenURL:   "https://secure.splitwise.com/oauth/access_token",
+		SplitwiseBaseURL: splitwise.DefaultBaseURL,
+		Port:             9094,
+		AllowedOrigins:   []string{"https://splitwise.atulmirajkar.com", "http://localhost:4200"},
+		SessionStore:     "memory",
+	}
+}
+
+/*RegisterConfigFlags - one flag per configuration field, e.g. -consumer-key for SPLITWISE_CONSUMER_KEY*/
+func RegisterConfigFlags(flags *flag.FlagSet) {
+	for _, field := range configFields() {
+		flags.String(field.flag, "", "sets "+field.name+", same as env var "+field.env)
+	}
+}
+
+/*LoadConfig - configuration layered as defaults < json file < SPLITWISE_* env vars < flags registered with RegisterConfigFlags, then validated. filePath and flags may be empty, a missing named file is an error*/
+func LoadConfig(filePath string, flags *flag.FlagSet) (*Configuration, error) {
+	conf := defaultConfiguration()
+	var problems ConfigErrors
+
+	if filePath != "" {
+		file, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading config file")
+		}
+		//unknown keys are most likely typos
+		decoder := json.NewDecoder(bytes.NewReader(file))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(conf); err != nil {
+			problems = append(problems, fmt.Sprintf("config file %s: %v", filePath, err))
+		}
+	}
+
+	fields := configFields()
+	value := reflect.ValueOf(conf).Elem()
+	for _, field := range fields {
+		if raw, ok := os.LookupEnv(field.env); ok {
+			if err := setConfigField(value.Field(field.index), raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: env var %s %v", field.name, field.env, err))
+			}
+		}
+	}
+	if flags != nil {
+		byFlag := make(map[string]configField)
+		for _, field := range fields {
+			byFlag[field.flag] = field
+		}
+		flags.Visit(func(set *flag.Flag) {
+			field, ok := byFlag[set.Name]
+			if !ok {
+				return
+			}
+			if err := setConfigField(value.Field(field.index), set.Value.String()); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: flag -%s %v", field.name, field.flag, err))
+			}
+		})
+	}
+
+	if conf.ConsumerSecretFile != "" {
+		secret, err := ioutil.ReadFile(conf.ConsumerSecretFile)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("ConsumerSecretFile: %v", err))
+		} else {
+			conf.ConsumerSecret = strings.TrimSpace(string(secret))
+		}
+	}
+
+	problems = append(problems, conf.Validate()...)
+	sort.Strings(problems)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return conf, nil
+}
+
+/*Validate - problems with the configuration, each naming the field and how to set it*/
+func (conf *Configuration) Validate() ConfigErrors {
+	var problems ConfigErrors
+	fields := make(map[string]configField)
+	for _, field := range configFields() {
+		fields[field.name] = field
+	}
+	report := func(name string, problem string) {
+		field := fields[name]
+		problems = append(problems, fmt.Sprintf("%s: %s (set %q in the config file, env var %s or flag -%s)", name, problem, name, field.env, field.flag))
+	}
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"ConsumerKey", conf.ConsumerKey},
+		{"CallbackURL", conf.CallbackURL},
+		{"AngularHandler", conf.AngularHandler},
+	}
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			report(field.name, "is required")
+		}
+	}
+	if conf.ConsumerSecret == "" && conf.ConsumerSecretFile == "" {
+		report("ConsumerSecret", "is required, or ConsumerSecretFile naming a file holding it")
+	}
+
+	urls := []struct {
+		name  string
+		value string
+	}{
+		{"AccessTokenURL", conf.AccessTokenURL},
+		{"AuthorizeURL", conf.AuthorizeURL},
+		{"RequestTokenURL", conf.RequestTokenURL},
+		{"CallbackURL", conf.CallbackURL},
+		{"AngularHandler", conf.AngularHandler},
+		{"SplitwiseBaseURL", conf.SplitwiseBaseURL},
+	}
+	for _, field := range urls {
+		if field.value != "" && !isAbsoluteURL(field.value) {
+			report(field.name, fmt.Sprintf("%q is not an absolute http(s) url", field.value))
+		}
+	}
+
+	if conf.Port < 1 || conf.Port > 65535 {
+		report("Port", fmt.Sprintf("%d is not a port between 1 and 65535", conf.Port))
+	}
+	if len(conf.AllowedOrigins) == 0 {
+		report("AllowedOrigins", "needs at least one origin")
+	}
+	for _, origin := range conf.AllowedOrigins {
+		if parsed, err := url.Parse(origin); err != nil || !isAbsoluteURL(origin) || strings.Trim(parsed.Path, "/") != "" {
+			report("AllowedOrigins", fmt.Sprintf("%q is not an origin like https://example.com", origin))
+		}
+	}
+
+	switch conf.SessionStore {
+	case "", "memory":
+	case "file":
+		if conf.SessionStorePath == "" {
+			report("SessionStorePath", "is required for the file session store")
+		}
+		//sessions outliving the process are useless when its random cookie keys do not
+		if conf.CookieHashKey == "" || conf.CookieBlockKey == "" {
+			report("CookieHashKey", "CookieHashKey and CookieBlockKey are required for the file session store")
+		}
+	default:
+		report("SessionStore", fmt.Sprintf("%q is not memory or file", conf.SessionStore))
+	}
+
+	if (conf.CookieHashKey == "") != (conf.CookieBlockKey == "") {
+		report("CookieBlockKey", "CookieHashKey and CookieBlockKey must be set together")
+	}
+	if blockKey := len(conf.CookieBlockKey); blockKey != 0 && blockKey != 16 && blockKey != 24 && blockKey != 32 {
+		report("CookieBlockKey", fmt.Sprintf("must be 16, 24 or 32 bytes long, not %d", blockKey))
+	}
+
+	if conf.ExchangeRatesPath != "" {
+		if _, err := os.Stat(conf.ExchangeRatesPath); err != nil {
+			report("ExchangeRatesPath", err.Error())
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+func isAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+/*configField - a Configuration field settable from env vars and flags*/
+type configField struct {
+	index int
+	name  string
+	env   string
+	flag  string
+}
+
+/*configFields - fields of Configuration with an env tag, the flag is the env var without prefix in kebab case*/
+func configFields() []configField {
+	configType := reflect.TypeOf(Configuration{})
+	fields := make([]configField, 0, configType.NumField())
+	for index := 0; index < configType.NumField(); index++ {
+		env := configType.Field(index).Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		fields = append(fields, configField{
+			index: index,
+			name:  configType.Field(index).Name,
+			env:   env,
+			flag:  strings.Replace(strings.ToLower(strings.TrimPrefix(env, envPrefix)), "_", "-", -1),
+		})
+	}
+	return fields
+}
+
+/*setConfigField - set a string, int or comma separated []string field from its text form*/
+func setConfigField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		number, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return errors.Errorf("%q is not a number", raw)
+		}
+		field.SetInt(int64(number))
+	case reflect.Slice:
+		values := make([]string, 0)
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return errors.Errorf("cannot be set from text")
+	}
+	return nil
+}
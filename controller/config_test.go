@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*clearConfigEnv - unset every SPLITWISE_ env var for the test, they are restored afterwards*/
+func clearConfigEnv(t *testing.T) {
+	for _, field := range configFields() {
+		if value, ok := os.LookupEnv(field.env); ok {
+			t.Setenv(field.env, value)
+			os.Unsetenv(field.env)
+		}
+	}
+}
+
+/*writeConfigFile - contents in a temp file, returns its path*/
+func writeConfigFile(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+/*configFlags - flags registered with RegisterConfigFlags and parsed from args*/
+func configFlags(t *testing.T, args ...string) *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+/*validConfiguration - defaults with the required fields set*/
+func validConfiguration() *Configuration {
+	conf := defaultConfiguration()
+	conf.ConsumerKey = "key"
+	conf.ConsumerSecret = "secret"
+	conf.CallbackURL = "https://api.example.com/expenses"
+	conf.AngularHandler = "https://example.com/"
+	return conf
+}
+
+func TestValidateFileSessionStoreNeedsCookieKeys(t *testing.T) {
+	conf := validConfiguration()
+	conf.SessionStore = "file"
+	conf.SessionStorePath = "sessions.json"
+
+	problems := conf.Validate()
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "CookieHashKey: ") {
+		t.Errorf("problems = %v, want the missing cookie keys", problems)
+	}
+
+	conf.CookieHashKey = strings.Repeat("h", 32)
+	conf.CookieBlockKey = strings.Repeat("b", 32)
+	if problems := conf.Validate(); len(problems) != 0 {
+		t.Errorf("problems = %v, want none", problems)
+	}
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "config.json", `{
+		"ConsumerKey": "file-key",
+		"ConsumerSecret": "file-secret",
+		"CallbackURL": "https://api.example.com/expenses",
+		"AngularHandler": "https://example.com/",
+		"Port": 8000,
+		"AllowedOrigins": ["https://file.example.com"]
+	}`)
+	t.Setenv("SPLITWISE_CONSUMER_KEY", "env-key")
+	t.Setenv("SPLITWISE_PORT", "8001")
+	t.Setenv("SPLITWISE_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+
+	conf, err := LoadConfig(path, configFlags(t, "-consumer-key", "flag-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//defaults < file < env vars < flags
+	if conf.AuthorizeURL != defaultConfiguration().AuthorizeURL || conf.SessionStore != "memory" {
+		t.Errorf("defaults were lost: %q, %q", conf.AuthorizeURL, conf.SessionStore)
+	}
+	if conf.ConsumerSecret != "file-secret" || conf.CallbackURL != "https://api.example.com/expenses" {
+		t.Errorf("file values were lost: %q, %q", conf.ConsumerSecret, conf.CallbackURL)
+	}
+	if conf.Port != 8001 {
+		t.Errorf("Port = %d, want 8001 from the env var", conf.Port)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(conf.AllowedOrigins, want) {
+		t.Errorf("AllowedOrigins = %q, want %q", conf.AllowedOrigins, want)
+	}
+	if conf.ConsumerKey != "flag-key" {
+		t.Errorf("ConsumerKey = %q, want flag-key from the flag", conf.ConsumerKey)
+	}
+
+	//without a file the env vars and flags are enough
+	t.Setenv("SPLITWISE_CONSUMER_SECRET", "env-secret")
+	t.Setenv("SPLITWISE_CALLBACK_URL", "https://api.example.com/expenses")
+	t.Setenv("SPLITWISE_ANGULAR_HANDLER", "https://example.com/")
+	if conf, err := LoadConfig("", configFlags(t)); err != nil || conf.ConsumerKey != "env-key" || conf.Port != 8001 {
+		t.Errorf("LoadConfig without a file = %+v, %v", conf, err)
+	}
+}
+
+func TestLoadConfigConsumerSecretFile(t *testing.T) {
+	clearConfigEnv(t)
+	secretPath := writeConfigFile(t, "consumer_secret", "  docker-secret\n")
+	path := writeConfigFile(t, "config.json", `{
+		"ConsumerKey": "key",
+		"ConsumerSecret": "file-secret",
+		"CallbackURL": "https://api.example.com/expenses",
+		"AngularHandler": "https://example.com/"
+	}`)
+
+	t.Setenv("SPLITWISE_CONSUMER_SECRET_FILE", secretPath)
+	conf, err := LoadConfig(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.ConsumerSecret != "docker-secret" {
+		t.Errorf("ConsumerSecret = %q, want the trimmed contents of the secret file", conf.ConsumerSecret)
+	}
+
+	t.Setenv("SPLITWISE_CONSUMER_SECRET_FILE", secretPath+".missing")
+	if _, err := LoadConfig(path, nil); err == nil || !strings.Contains(err.Error(), "ConsumerSecretFile: ") {
+		t.Errorf("missing secret file: %v", err)
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "config.json", `{
+		"ConsumerKey": "key",
+		"ConsumerSecret": "secret",
+		"CallbackURL": "https://api.example.com/expenses",
+		"AngularHandler": "https://example.com/",
+		"SesionStore": "file"
+	}`)
+
+	_, err := LoadConfig(path, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown field "SesionStore"`) {
+		t.Errorf("misspelled key: %v", err)
+	}
+	if _, err := LoadConfig(path+".missing", nil); err == nil {
+		t.Error("missing config file was ignored")
+	}
+}
+
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("SPLITWISE_PORT", "eighty")
+	t.Setenv("SPLITWISE_SESSION_STORE", "redis")
+
+	_, err := LoadConfig("", configFlags(t, "-callback-url", "/expenses"))
+	problems, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("error %v is not a ConfigErrors", err)
+	}
+	want := []string{
+		"AngularHandler: is required",
+		`CallbackURL: "/expenses" is not an absolute http(s) url`,
+		"ConsumerKey: is required",
+		"ConsumerSecret: is required",
+		`Port: env var SPLITWISE_PORT "eighty" is not a number`,
+		`SessionStore: "redis" is not memory or file`,
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("problems:\n%v\nwant %d", err, len(want))
+	}
+	for index, problem := range problems {
+		if !strings.HasPrefix(problem, want[index]) {
+			t.Errorf("problem %d = %q, want it to start with %q", index, problem, want[index])
+		}
+	}
+	//each problem tells where the field can be set
+	if !strings.Contains(problems[0], "env var SPLITWISE_ANGULAR_HANDLER or flag -angular-handler") {
+		t.Errorf("problem %q does not name the env var and flag", problems[0])
+	}
+}
@@ -1,12 +1,12 @@
 package controller
 
 import (
-	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,7 +14,14 @@ import (
 	"strings"
 	"time"
 
+	"splitwiseAngularAPI/budget"
+	"splitwiseAngularAPI/currency"
+	"splitwiseAngularAPI/daterange"
 	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/mirror"
+	"splitwiseAngularAPI/recurring"
+	"splitwiseAngularAPI/rules"
+	"splitwiseAngularAPI/splitwise"
 
 	"github.com/dghubble/oauth1"
 	"github.com/gorilla/securecookie"
@@ -22,54 +29,143 @@ import (
 )
 
 type sessionValues struct {
+	//user - splitwise user id the session belongs to
+	user string
+	//timeZone - time zone of the user's splitwise profile, day boundaries are taken in it
+	timeZone  string
 	sessionID string
 	token     *oauth1.Token
+	expiresAt time.Time
 }
 
-/*Configuration - structure for configuration*/
+func (session *sessionValues) expired(now time.Time) bool {
+	return !session.expiresAt.After(now)
+}
+
+/*Configuration - structure for configuration, see LoadConfig for where values come from*/
 type Configuration struct {
-	AccessTokenURL  string `json:"AccessTokenURL"`
-	AuthorizeURL    string `json:"AuthorizeURL"`
-	RequestTokenURL string `json:"RequestTokenURL"`
-	ConsumerKey     string `json:"ConsumerKey"`
-	ConsumerSecret  string `json:"ConsumerSecret"`
-	CallbackURL     string `json: "CallbackURL"`
-	AngularHandler  string `json: "AngularHandler"`
+	AccessTokenURL  string `json:"AccessTokenURL" env:"SPLITWISE_ACCESS_TOKEN_URL"`
+	AuthorizeURL    string `json:"AuthorizeURL" env:"SPLITWISE_AUTHORIZE_URL"`
+	RequestTokenURL string `json:"RequestTokenURL" env:"SPLITWISE_REQUEST_TOKEN_URL"`
+	ConsumerKey     string `json:"ConsumerKey" env:"SPLITWISE_CONSUMER_KEY"`
+	ConsumerSecret  string `json:"ConsumerSecret" env:"SPLITWISE_CONSUMER_SECRET"`
+	//ConsumerSecretFile - file holding ConsumerSecret (e.g. a docker secret), read at startup and preferred over ConsumerSecret
+	ConsumerSecretFile string `json:"ConsumerSecretFile" env:"SPLITWISE_CONSUMER_SECRET_FILE"`
+	CallbackURL        string `json:"CallbackURL" env:"SPLITWISE_CALLBACK_URL"`
+	AngularHandler     string `json:"AngularHandler" env:"SPLITWISE_ANGULAR_HANDLER"`
+	//Port - port the api listens on
+	Port int `json:"Port" env:"SPLITWISE_PORT"`
+	//AllowedOrigins - origins allowed to call the api with credentials, comma separated in env vars and flags
+	AllowedOrigins []string `json:"AllowedOrigins" env:"SPLITWISE_ALLOWED_ORIGINS"`
+	//SessionStore - memory (default) or file
+	SessionStore string `json:"SessionStore" env:"SPLITWISE_SESSION_STORE"`
+	//SessionStorePath - file used by the file session store, logins in progress are kept in SessionStorePath.logins
+	SessionStorePath string `json:"SessionStorePath" env:"SPLITWISE_SESSION_STORE_PATH"`
+	//CookieHashKey, CookieBlockKey - fixed cookie keys so cookies stay valid across
+	//restarts and replicas, required by the file session store, random keys are generated when empty
+	CookieHashKey  string `json:"CookieHashKey" env:"SPLITWISE_COOKIE_HASH_KEY"`
+	CookieBlockKey string `json:"CookieBlockKey" env:"SPLITWISE_COOKIE_BLOCK_KEY"`
+	//SplitwiseBaseURL - splitwise api, defaults to the public v3.0 api
+	SplitwiseBaseURL string `json:"SplitwiseBaseURL" env:"SPLITWISE_BASE_URL"`
+	//ExchangeRatesPath - optional dated exchange rate table for ?reportCurrency=
+	ExchangeRatesPath string `json:"ExchangeRatesPath" env:"SPLITWISE_EXCHANGE_RATES_PATH"`
+	//RulesPath - file storing categorization rules, kept in memory when empty
+	RulesPath string `json:"RulesPath" env:"SPLITWISE_RULES_PATH"`
+	//RecurringPath - file storing recurring expenses and their runs, kept in memory when empty
+	RecurringPath string `json:"RecurringPath" env:"SPLITWISE_RECURRING_PATH"`
+	//BudgetsPath - file storing budgets, kept in memory when empty
+	BudgetsPath string `json:"BudgetsPath" env:"SPLITWISE_BUDGETS_PATH"`
+	//MirrorPath - directory mirroring the groups and expenses of logged in users, kept in memory when empty
+	MirrorPath string `json:"MirrorPath" env:"SPLITWISE_MIRROR_PATH"`
 }
 
 //Trace - logger
 var Trace *log.Logger
 
+//splitwiseTimeout - a splitwise call taking longer fails with a 504
+const splitwiseTimeout = 30 * time.Second
+
 var splitwiseEndPoint = new(oauth1.Endpoint)
 
 var splitwiseAuthConfig = new(oauth1.Config)
 
-var requestTok = ""
-var requestSec = ""
+var requestTokens = newRequestTokenStore(requestTokenTTL)
 
 var cookieHandler = securecookie.New(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
-var sessionMapper = make(map[string]*sessionValues)
+var sessionStore SessionStore = newMemorySessionStore()
 
 var config = new(Configuration)
 
 //ConfigFilePath - config file path
 var ConfigFilePath string
 
-//InitializeConfig initialize config file
-func InitializeConfig(filePath string) {
-	//read json file
-	file, err := ioutil.ReadFile(filePath)
+/*InitializeConfig - load and validate the configuration, apply it and start the background jobs, exits with a report of every problem when the configuration is invalid. filePath may be empty, flags are the parsed command line*/
+func InitializeConfig(filePath string, flags *flag.FlagSet) *Configuration {
+	conf, err := LoadConfig(filePath, flags)
 	if err != nil {
-		fmt.Println("error reading config file - Exiting")
+		fmt.Println(err)
+		fmt.Println("Exiting")
 		os.Exit(1)
 	}
 
-	//marshall configuration object
-	err = json.Unmarshal(file, config)
+	err = Configure(conf)
 	if err != nil {
-		fmt.Println("error reading config file - Exiting", err)
+		fmt.Println("error applying config - Exiting", err)
 		os.Exit(1)
 	}
+	go expireSessions(sessionStore)
+	go scheduleRecurring()
+	go syncMirrors()
+	return conf
+}
+
+/*Configure - set up oauth, cookies and the session store from conf*/
+func Configure(conf *Configuration) error {
+	store, err := newSessionStore(conf)
+	if err != nil {
+		return errors.Wrap(err, "error creating session store")
+	}
+	tokens, err := requestTokenStoreFor(conf)
+	if err != nil {
+		return errors.Wrap(err, "error creating request token store")
+	}
+
+	var rates *currency.RateTable
+	if conf.ExchangeRatesPath != "" {
+		rates, err = currency.LoadRateTable(conf.ExchangeRatesPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	ruleStore, err := rules.NewStore(conf.RulesPath)
+	if err != nil {
+		return err
+	}
+
+	recurringStore, err := recurring.NewStore(conf.RecurringPath)
+	if err != nil {
+		return err
+	}
+
+	budgetStore, err := budget.NewStore(conf.BudgetsPath)
+	if err != nil {
+		return err
+	}
+
+	userMirror, err := mirror.New(conf.MirrorPath)
+	if err != nil {
+		return err
+	}
+
+	config = conf
+	sessionStore = store
+	requestTokens = tokens
+	exchangeRates = rates
+	categoryRules = ruleStore
+	recurringExpenses = recurringStore
+	budgets = budgetStore
+	expenseMirror = userMirror
 
 	splitwiseEndPoint = &oauth1.Endpoint{
 		AccessTokenURL:  config.AccessTokenURL,
@@ -82,6 +178,22 @@ func InitializeConfig(filePath string) {
 		ConsumerSecret: config.ConsumerSecret,
 		CallbackURL:    config.CallbackURL,
 		Endpoint:       *splitwiseEndPoint,
+		//oauth1 sets a missing Noncer on every request, concurrent logins would race on it
+		Noncer: oauth1.Base64Noncer{},
+	}
+
+	if config.CookieHashKey != "" && config.CookieBlockKey != "" {
+		cookieHandler = securecookie.New([]byte(config.CookieHashKey), []byte(config.CookieBlockKey))
+	}
+	return nil
+}
+
+/*expireSessions - periodically drop expired sessions from the store*/
+func expireSessions(store SessionStore) {
+	for range time.Tick(10 * time.Minute) {
+		if err := store.Expire(); err != nil {
+			Trace.Println("error expiring sessions", err)
+		}
 	}
 }
 
@@ -106,15 +218,20 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 
 	//1. Your application requests authorization
 	requestToken, requestSecret, err := splitwiseAuthConfig.RequestToken()
-	requestTok = requestToken
-	requestSec = requestSecret
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		Trace.Println(err)
+		writeJSONError(w, http.StatusBadGateway, "error requesting a splitwise token")
+		return
+	}
+	if err := requestTokens.put(requestToken, requestSecret); err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error saving the login")
 		return
 	}
 	authorizationURL, err := splitwiseAuthConfig.AuthorizationURL(requestToken)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error building the splitwise authorization url")
 		return
 	}
 	http.Redirect(w, r, authorizationURL.String(), http.StatusFound)
@@ -126,19 +243,34 @@ func CompleteAuth(w http.ResponseWriter, r *http.Request) {
 	// use the token to get an authenticated client
 	requestTok, verifier, err := oauth1.ParseAuthorizationCallback(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeJSONError(w, http.StatusBadRequest, "invalid authorization callback")
+		return
+	}
+	//unknown, expired or already used request token
+	requestSec, ok, err := requestTokens.take(requestTok)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error reading the login")
+		return
+	}
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "unknown or expired request token, log in again")
 		return
 	}
 	accessToken, accessSecret, err := splitwiseAuthConfig.AccessToken(requestTok, requestSec, verifier)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		Trace.Println(err)
+		writeJSONError(w, http.StatusUnauthorized, "splitwise did not grant access")
 		return
 	}
 
 	sessionToken := oauth1.NewToken(accessToken, accessSecret)
 	//cache = map[user]{sessionid,sessiontoken}
 	//cookie = {user,sessionid}
-	setCookieAndCache(w, sessionToken)
+	if err := setCookieAndCache(w, sessionToken); err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
@@ -150,14 +282,18 @@ func CompleteAuth(w http.ResponseWriter, r *http.Request) {
 //cache = map[user]{sessionid,sessiontoken}
 //cookie = {user,sessionid}
 */
-func setCookieAndCache(w http.ResponseWriter, sessionToken *oauth1.Token) {
+func setCookieAndCache(w http.ResponseWriter, sessionToken *oauth1.Token) error {
 
-	user := getCurrentUserID(sessionToken)
-	if user == "" {
-		return
+	currentUser, err := getCurrentUser(sessionToken)
+	if err != nil {
+		return err
 	}
+	user := strconv.Itoa(currentUser.ID)
 
-	sessionID := createSessionID()
+	sessionID, err := createSessionID()
+	if err != nil {
+		return err
+	}
 
 	cookieVal := map[string]string{
 		"username":  user,
@@ -165,23 +301,40 @@ func setCookieAndCache(w http.ResponseWriter, sessionToken *oauth1.Token) {
 	}
 	cookieEncoded, err := cookieHandler.Encode("clientMap", cookieVal)
 	if err != nil {
-		Trace.Println("error encoding cookie")
-		errors.Wrap(err, "error encoding cookie")
-
+		return errors.Wrap(err, "error encoding cookie")
 	}
 	cookie := &http.Cookie{
 		Name:   "clientMap",
 		Value:  cookieEncoded,
 		Path:   "/",
-		MaxAge: 60 * 60,
+		MaxAge: sessionMaxAge,
 	}
 	http.SetCookie(w, cookie)
 
-	//save session in a map
-	sessionMapper[user] = &sessionValues{sessionID: sessionID, token: sessionToken}
+	//save session in the store
+	session := &sessionValues{
+		user:      user,
+		timeZone:  currentUser.TimeZone,
+		sessionID: sessionID,
+		token:     sessionToken,
+		expiresAt: time.Now().Add(sessionMaxAge * time.Second),
+	}
+	if err := sessionStore.Put(user, session); err != nil {
+		Trace.Println("error saving session", err)
+	}
+
+	//recurring expenses are posted with the newest token
+	credentials := recurring.Credentials{Token: sessionToken.Token, TokenSecret: sessionToken.TokenSecret}
+	if err := recurringExpenses.UpdateCredentials(user, credentials); err != nil {
+		Trace.Println("error saving recurring expense token", err)
+	}
+
+	//fill the mirror so the first GetGroupData is served locally
+	go syncMirror(user, newSplitwiseClient(user, sessionToken))
+	return nil
 }
 
-func clearCookieAndCache(w http.ResponseWriter, request *http.Request) {
+func clearCookieAndCache(w http.ResponseWriter, user string) {
 	cookie := &http.Cookie{
 		Name:   "clientMap",
 		Value:  "",
@@ -190,38 +343,25 @@ func clearCookieAndCache(w http.ResponseWriter, request *http.Request) {
 	}
 	http.SetCookie(w, cookie)
 
-	var cookieUserName string
-
-	//get cookie from client request
-	cookie, err := request.Cookie("clientMap")
-	if err != nil {
-		Trace.Println(err)
+	//remove session from the store
+	if err := sessionStore.Delete(user); err != nil {
+		Trace.Println("error deleting session", err)
 	}
+}
 
-	cookieValue := make(map[string]string)
-	err = cookieHandler.Decode("clientMap", cookie.Value, &cookieValue)
-	if err != nil {
-		Trace.Println(err)
+/*createSessionID - 256 random bits, sessions outlive restarts so the id must not be guessable*/
+func createSessionID() (string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", errors.Wrap(err, "error creating session id")
 	}
-
-	cookieUserName = cookieValue["username"]
-
-	//save session in a map
-	delete(sessionMapper, cookieUserName)
-}
-func createSessionID() string {
-	//return a random string
-	timeNow := time.Now()
-	randSource := timeNow.Hour()*3600 + timeNow.Minute()*60 + timeNow.Second()
-	r := rand.New(rand.NewSource(int64(randSource)))
-	return strconv.Itoa(r.Intn(100000))
+	return hex.EncodeToString(id), nil
 }
 
-/*called from each http get request from angular front end*/
+/*validateSessionAndGetUser - session of the request's cookie, nil without a valid one. Handlers get it from requestSession, Authenticate calls this once per request*/
 func validateSessionAndGetUser(request *http.Request) *sessionValues {
 	var cookieUserName string
 	var cookieSession string
-	var storedSession string
 
 	//get cookie from client request
 	cookie, err := request.Cookie("clientMap")
@@ -239,26 +379,28 @@ func validateSessionAndGetUser(request *http.Request) *sessionValues {
 
 	cookieUserName = cookieValue["username"]
 	cookieSession = cookieValue["sessionid"]
-	storedSession = sessionMapper[cookieUserName].sessionID
+
+	//get stored session from server
+	storedSession, err := sessionStore.Get(cookieUserName)
+	if err != nil {
+		Trace.Println(err)
+		return nil
+	}
+
 	//compare session ids
-	if cookieSession != storedSession {
+	if storedSession == nil || cookieSession != storedSession.sessionID {
 		return nil
 	}
 
-	//get stored sessionid from server
-	return sessionMapper[cookieUserName]
+	return storedSession
 
 }
 
 /*Logout - clear cookie nad cache*/
 func Logout(w http.ResponseWriter, r *http.Request) {
-	sessionVals := validateSessionAndGetUser(r)
-	if sessionVals == nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
+	sessionVals := requestSession(r)
 
-	clearCookieAndCache(w, r)
+	clearCookieAndCache(w, sessionVals.user)
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -270,7 +412,11 @@ func refreshSession(w http.ResponseWriter, r *http.Request) bool {
 		return false
 	}
 
-	setCookieAndCache(w, sessionVals.token)
+	//a token splitwise no longer accepts starts a new login
+	if err := setCookieAndCache(w, sessionVals.token); err != nil {
+		Trace.Println(err)
+		return false
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -278,162 +424,73 @@ func refreshSession(w http.ResponseWriter, r *http.Request) bool {
 	return true
 }
 
-/*getCurrentUserID - Given a session token return current user ID*/
-func getCurrentUserID(sessionToken *oauth1.Token) string {
-	// httpClient will automatically authorize http.Request's
-	httpClient := splitwiseAuthConfig.Client(oauth1.NoContext, sessionToken)
-	response, err := httpClient.Get("https://secure.splitwise.com/api/v3.0/get_current_user")
-	if err != nil {
-		return ""
-	}
-
-	defer response.Body.Close()
-	contents, err := ioutil.ReadAll(response.Body)
-
-	var userData interface{}
-	err = json.Unmarshal(contents, &userData)
-	if err != nil {
-		return ""
-	}
-
-	userDataObj := userData.(map[string]interface{})
-	userDataMap := userDataObj["user"].(map[string]interface{})
-
-	return strconv.FormatFloat(userDataMap["id"].(float64), 'f', 0, 64)
-
+/*getCurrentUser - Given a session token return current user*/
+func getCurrentUser(sessionToken *oauth1.Token) (*expense.User, error) {
+	return newSplitwiseClient("", sessionToken).GetCurrentUser()
 }
 
-/*GetExpenseURLForGroup - Expense for a group*/
-func GetExpenseURLForGroup(groupID string, startDate time.Time, endDate time.Time) string {
-	requestURL, _ := url.Parse("https://secure.splitwise.com/api/v3.0/get_expenses")
-	requestQuery := requestURL.Query()
-	requestQuery.Set("group_id", groupID)
-	requestQuery.Set("dated_after", startDate.String())
-	requestQuery.Set("dated_before", endDate.String())
-	requestQuery.Set("limit", "0")
-	requestURL.RawQuery = requestQuery.Encode()
-	return requestURL.String()
+/*newSplitwiseClient - splitwise client authorized with the user's token, caching the user's groups and categories unless user is empty*/
+func newSplitwiseClient(user string, sessionToken *oauth1.Token) *splitwise.Client {
+	// httpClient will automatically authorize http.Request's
+	httpClient := splitwiseAuthConfig.Client(oauth1.NoContext, sessionToken)
+	httpClient.Timeout = splitwiseTimeout
+	client := splitwise.NewClient(httpClient, config.SplitwiseBaseURL)
+	client.SetCache(user, groupCache, categoryCache)
+	return client
 }
 
-/*getUserInfo- User info within a group*/
-func getUserInfo(userArr []interface{}) string {
-	var userLine = ""
-	for _, user := range userArr {
-		userMap := user.(map[string]interface{})
-		userInfoMap := userMap["user"].(map[string]interface{})
-		userName := userInfoMap["first_name"].(string)
-		userName = strings.Replace(userName, ",", "", -1)
-		userShare := ""
-		if userMap["owed_share"] != nil {
-			userShare = userMap["owed_share"].(string)
-			userShare = strings.Replace(userShare, ",", "", -1)
-
-		}
-
-		tempStrArr := []string{userName, userShare}
-		tempStr := strings.Join(tempStrArr, "_")
-		if userLine == "" {
-			userLine = tempStr
-		} else {
-			userLineArr := []string{userLine, tempStr}
-			userLine = strings.Join(userLineArr, ",")
-		}
-
-	}
-	return userLine
+/*requestClient - splitwise client of the request's session, its calls are cancelled when the request ends or times out*/
+func requestClient(r *http.Request) *splitwise.Client {
+	session := requestSession(r)
+	return newSplitwiseClient(session.user, session.token).WithContext(r.Context())
 }
 
 /*GetGroups - get groups for current user*/
 func GetGroups(w http.ResponseWriter, r *http.Request) {
-	//get session values
-	sessionVals := validateSessionAndGetUser(r)
-	if sessionVals == nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	httpClient := splitwiseAuthConfig.Client(oauth1.NoContext, sessionVals.token)
-	response, err := httpClient.Get("https://secure.splitwise.com/api/v3.0/get_groups")
+	groups, err := requestClient(r).GetGroups()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	defer response.Body.Close()
-	contents, err := ioutil.ReadAll(response.Body)
-
-	//unmarshall to expense object
-	var groupArrWrapper expense.GroupArrWrapper
-	json.Unmarshal(contents, &groupArrWrapper)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeSplitwiseError(w, err)
 		return
 	}
 
 	//send response
-	groupIDNameJSON, err := json.Marshal(groupArrWrapper.Groups)
+	groupIDNameJSON, err := json.Marshal(groups)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	w.Write(groupIDNameJSON)
+	//groups change with every expense, the client revalidates with the ETag
+	writeCacheable(w, r, groupIDNameJSON, 0)
 }
 
 /*GetGroupUsers - get users for a group*/
 func GetGroupUsers(w http.ResponseWriter, r *http.Request) {
-	//get session values
-	sessionVals := validateSessionAndGetUser(r)
-	if sessionVals == nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
 	//read request body
 	defer r.Body.Close()
-	reqURL := r.RequestURI
-	u, err := url.Parse(reqURL)
+	groupID, err := strconv.Atoi(r.URL.Query().Get("groupID"))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
 		return
 	}
-	q := u.Query()
-	groupID := q["groupID"][0]
 
-	httpClient := splitwiseAuthConfig.Client(oauth1.NoContext, sessionVals.token)
-
-	requestURL, _ := url.Parse("https://secure.splitwise.com/api/v3.0/get_group")
-	requestQuery := requestURL.Query()
-	requestQuery.Set("id", groupID)
-	requestURL.RawQuery = requestQuery.Encode()
-
-	response, err := httpClient.Get(requestURL.String())
+	group, err := requestClient(r).GetGroup(groupID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeSplitwiseError(w, err)
 		return
 	}
 
-	defer response.Body.Close()
-
-	//create object to send
-	contents, _ := ioutil.ReadAll(response.Body)
-
-	//unmarshall to expense object
-	var groupWrapper expense.GroupWrapper
-	json.Unmarshal(contents, &groupWrapper)
-
-	//extract individual expenses
-	memberArr := extractMembers(groupWrapper)
+	//extract members
+	memberArr := extractMembers(group)
 
 	//send response
 	contentJSON, err := json.Marshal(memberArr)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -447,40 +504,75 @@ func GetGroupUsers(w http.ResponseWriter, r *http.Request) {
 /*GetGroupData - Get group data*/
 func GetGroupData(w http.ResponseWriter, r *http.Request) {
 	//get session values
-	sessionVals := validateSessionAndGetUser(r)
-	if sessionVals == nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
+	sessionVals := requestSession(r)
 
 	//read request body
 	defer r.Body.Close()
-	reqURL := r.RequestURI
-	u, err := url.Parse(reqURL)
+	q := r.URL.Query()
+	groupID, err := strconv.Atoi(q.Get("groupID"))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
+		return
+	}
+	startDate, endDate, err := getStartAndEndDate(q, sessionVals)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reportCurrency, err := reportCurrencyFromQuery(q)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	excludePayments := false
+	if value := q.Get("excludePayments"); value != "" {
+		excludePayments, err = strconv.ParseBool(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "excludePayments must be true or false")
+			return
+		}
 	}
-	q := u.Query()
-	groupID := q["groupID"][0]
-	startDate, endDate := getStartAndEndDate(q)
 
-	httpClient := splitwiseAuthConfig.Client(oauth1.NoContext, sessionVals.token)
-	requestURL := GetExpenseURLForGroup(groupID, startDate, endDate)
-	expenseResponse, _ := httpClient.Get(requestURL)
-	contents, _ := ioutil.ReadAll(expenseResponse.Body)
+	cursor, pageSize, paged, err := pageFromQuery(q, groupID, startDate, endDate)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	//unmarshall to expense object
-	var expensesWrapper expense.ExpensesWrapper
-	json.Unmarshal(contents, &expensesWrapper)
+	expensesQuery := splitwise.ExpensesQuery{
+		GroupID:     groupID,
+		DatedAfter:  startDate,
+		DatedBefore: endDate,
+	}
+	if paged {
+		//one more than a page tells whether there is a next page
+		expensesQuery.Limit = pageSize + 1
+		expensesQuery.Offset = cursor.Offset
+	}
+	expenses, err := mirroredExpenses(w, r, expensesQuery)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	if paged && len(expenses) > pageSize {
+		expenses = expenses[:pageSize]
+		cursor.Offset += pageSize
+		w.Header().Set(nextCursorHeader, cursor.encode())
+	}
 
 	//extract individual expenses
-	userInfoArr := extractExpenses(expensesWrapper)
+	userInfoArr, err := extractExpenses(expenses, reportCurrency, excludePayments)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
 
 	//send response
 	contentJSON, err := json.Marshal(userInfoArr)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -488,39 +580,30 @@ func GetGroupData(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", strings.Join([]string{nextCursorHeader, dataSourceHeader, mirrorSyncedAtHeader, mirrorStaleHeader}, ", "))
 	w.Write(contentJSON)
 
 }
 
 func GetCategories(w http.ResponseWriter, r *http.Request) {
-	//get session values
-	sessionVals := validateSessionAndGetUser(r)
-	if sessionVals == nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
 	//read request body
 	defer r.Body.Close()
 
 	//make splitwise request
-	httpClient := splitwiseAuthConfig.Client(oauth1.NoContext, sessionVals.token)
-	categoriesResponse, _ := httpClient.Get("https://secure.splitwise.com/api/v3.0/get_categories")
-
-	contents, _ := ioutil.ReadAll(categoriesResponse.Body)
-
-	//unmarshall to expense object
-	var categoriesWrapper expense.CategoryWrapper
-	json.Unmarshal(contents, &categoriesWrapper)
+	categories, err := requestClient(r).GetCategories()
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
 
-	//extract individual expenses
-	categoriesArr := extractCategories(categoriesWrapper)
+	//flatten categories and subcategories
+	categoriesArr := extractCategories(categories)
 
 	//send response
 	contentJSON, err := json.Marshal(categoriesArr)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -528,13 +611,13 @@ func GetCategories(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Write(contentJSON)
+	writeCacheable(w, r, contentJSON, categoryCache.TTL())
 }
 
-func extractCategories(categoriesWrapper expense.CategoryWrapper) []expense.Subcategories {
+func extractCategories(categories []expense.Categories) []expense.Subcategories {
 	responseCategoriesArr := make([]expense.Subcategories, 0)
 
-	for _, individualCategory := range categoriesWrapper.Categories {
+	for _, individualCategory := range categories {
 		responseCategoriesArr = append(responseCategoriesArr, expense.Subcategories{ID: individualCategory.ID, Name: individualCategory.Name})
 		for _, indSubCategory := range individualCategory.Subcategories {
 			responseCategoriesArr = append(responseCategoriesArr, expense.Subcategories{ID: indSubCategory.ID, Name: indSubCategory.Name})
@@ -544,40 +627,116 @@ func extractCategories(categoriesWrapper expense.CategoryWrapper) []expense.Subc
 	return responseCategoriesArr
 }
 
-func extractExpenses(expensesWrapper expense.ExpensesWrapper) []expense.ResponseExpense {
-	expenseArr := expensesWrapper.Expenses
+func extractExpenses(expenseArr []expense.Expense, reportCurrency string, excludePayments bool) ([]expense.ResponseExpense, error) {
 	emptyTime := time.Time{}
 	responseExpenseArr := make([]expense.ResponseExpense, 0)
 	for _, individualExpense := range expenseArr {
-		for _, userInfo := range individualExpense.Users {
-			//add if not deleted
-			if individualExpense.DeletedAt == emptyTime {
-				responseExpenseArr = append(responseExpenseArr, expense.ResponseExpense{Category: individualExpense.Category.Name, UserID: userInfo.UserID, OwedShare: userInfo.OwedShare, Date: individualExpense.Date, Description: individualExpense.Description})
+		//add if not deleted
+		if individualExpense.DeletedAt != emptyTime {
+			continue
+		}
+		if excludePayments && individualExpense.Payment {
+			continue
+		}
+
+		var convertedExpense expense.Expense
+		exchangeRate := ""
+		if reportCurrency != "" {
+			converted, rate, err := exchangeRates.ConvertExpense(individualExpense, reportCurrency)
+			if err != nil {
+				return nil, err
 			}
+			convertedExpense = converted
+			exchangeRate = currency.FormatRate(rate)
+		}
+
+		for index, userInfo := range individualExpense.Users {
+			responseExpense, err := newResponseExpense(individualExpense, userInfo)
+			if err != nil {
+				return nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+			}
+
+			if reportCurrency != "" {
+				reportOwedShare, _ := convertedExpense.Users[index].Owed(reportCurrency)
+				responseExpense.ReportCurrency = reportCurrency
+				responseExpense.ReportOwedShare = &reportOwedShare
+				responseExpense.ExchangeRate = exchangeRate
+			}
+			responseExpenseArr = append(responseExpenseArr, responseExpense)
 		}
 	}
-	return responseExpenseArr
+	return responseExpenseArr, nil
 }
 
-func extractMembers(groupWrapper expense.GroupWrapper) []expense.Members {
-	return groupWrapper.Group.Members
+/*newResponseExpense - row for one user of an expense*/
+func newResponseExpense(individualExpense expense.Expense, userInfo expense.UserInfo) (expense.ResponseExpense, error) {
+	currencyCode := individualExpense.CurrencyCode
+	owedShare, err := userInfo.Owed(currencyCode)
+	if err != nil {
+		return expense.ResponseExpense{}, err
+	}
+	paidShare, err := userInfo.Paid(currencyCode)
+	if err != nil {
+		return expense.ResponseExpense{}, err
+	}
+	netBalance, err := userInfo.Net(currencyCode)
+	if err != nil {
+		return expense.ResponseExpense{}, err
+	}
+	cost, err := expense.ParseMoney(individualExpense.Cost, currencyCode)
+	if err != nil {
+		return expense.ResponseExpense{}, err
+	}
+
+	createdBy := 0
+	if individualExpense.CreatedBy != nil {
+		createdBy = individualExpense.CreatedBy.ID
+	}
+
+	return expense.ResponseExpense{
+		ExpenseID:    individualExpense.ID,
+		Category:     individualExpense.Category.Name,
+		UserID:       userInfo.UserID,
+		OwedShare:    owedShare,
+		PaidShare:    paidShare,
+		NetBalance:   netBalance,
+		Cost:         cost,
+		Payment:      individualExpense.Payment,
+		CreatedBy:    createdBy,
+		Date:         individualExpense.Date,
+		Description:  individualExpense.Description,
+		CurrencyCode: currencyCode,
+	}, nil
 }
 
-func getStartAndEndDate(query url.Values) (time.Time, time.Time) {
-	startYear, _ := strconv.Atoi(query["startYear"][0])
-	startMonth, _ := strconv.Atoi(query["startMonth"][0])
-	startDate, _ := strconv.Atoi(query["startDay"][0])
-	endYear, _ := strconv.Atoi(query["endYear"][0])
-	endMonth, _ := strconv.Atoi(query["endMonth"][0])
-	endDate, _ := strconv.Atoi(query["endDay"][0])
-	endDate = endDate + 1 //before end of today's date
+func extractMembers(group *expense.Group) []expense.Members {
+	return group.Members
+}
 
-	loc := time.FixedZone("UTC", 0)
+/*getStartAndEndDate - requested date range in ?tz= or the user's time zone, see daterange.FromQuery*/
+func getStartAndEndDate(query url.Values, session *sessionValues) (time.Time, time.Time, error) {
+	return daterange.FromQuery(query, time.Now(), sessionLocation(session))
+}
 
-	startTime := time.Date(startYear, time.Month(startMonth), startDate, 0, 0, 0, 0, loc)
-	endTime := time.Date(endYear, time.Month(endMonth), endDate, 0, 0, 0, 0, loc)
+/*expensesInLocation - expenses with dates in location so days and months follow the user's calendar*/
+func expensesInLocation(expenses []expense.Expense, location *time.Location) []expense.Expense {
+	for index := range expenses {
+		expenses[index].Date = expenses[index].Date.In(location)
+	}
+	return expenses
+}
 
-	return startTime, endTime
+/*sessionLocation - time zone of the user's splitwise profile, UTC when it is unknown*/
+func sessionLocation(session *sessionValues) *time.Location {
+	if session.timeZone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(session.timeZone)
+	if err != nil {
+		Trace.Println("unknown profile time zone", session.timeZone)
+		return time.UTC
+	}
+	return location
 }
 
 func CreateExpense(w http.ResponseWriter, r *http.Request) {
@@ -592,33 +751,34 @@ func CreateExpense(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//get session values
-	sessionVals := validateSessionAndGetUser(r)
-	if sessionVals == nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
+	sessionVals := requestSession(r)
 
 	//read request body
-
-	expenseObjByte, _ := ioutil.ReadAll(r.Body)
-	fmt.Println(string(expenseObjByte))
-	Trace.Println(string(expenseObjByte))
+	var request expense.CreateExpenseRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	defer r.Body.Close()
-
-	requestURL, _ := url.Parse("https://secure.splitwise.com/api/v3.0/create_expense")
-
-	httpClient := splitwiseAuthConfig.Client(oauth1.NoContext, sessionVals.token)
-	response, err := httpClient.Post(requestURL.String(), "application/json", bytes.NewBuffer(expenseObjByte))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeDecodeError(w, "invalid expense: ", err)
 		return
 	}
 
-	defer response.Body.Close()
-
-	//create object to send
-	contents, _ := ioutil.ReadAll(response.Body)
-
-	w.Write([]byte(contents))
+	applyCategoryRules(sessionVals.user, &request)
+	expenses, validationErrors, err := createExpense(requestClient(r), &request)
+	if validationErrors != nil {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, validationErrors)
+		return
+	}
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	expenseMirror.MarkStale(sessionVals.user)
 
+	//send created expenses
+	contentJSON, err := json.Marshal(expense.ExpensesWrapper{Expenses: expenses})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Write(contentJSON)
 }
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"net/url"
+	"strings"
+
+	"splitwiseAngularAPI/currency"
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+//exchangeRates - rate table from config.ExchangeRatesPath, nil when not configured
+var exchangeRates *currency.RateTable
+
+/*reportCurrencyFromQuery - optional ?reportCurrency=, empty when amounts stay in the expense currency*/
+func reportCurrencyFromQuery(q url.Values) (string, error) {
+	reportCurrency := strings.ToUpper(q.Get("reportCurrency"))
+	if reportCurrency == "" {
+		return "", nil
+	}
+	if !expense.IsCurrencyCode(reportCurrency) {
+		return "", errors.New("reportCurrency must be a three letter ISO currency code")
+	}
+	if exchangeRates == nil {
+		return "", errors.New("exchange rates are not configured")
+	}
+	return reportCurrency, nil
+}
+
+/*convertExpenses - expenses with amounts converted to reportCurrency at each expense's date*/
+func convertExpenses(expenses []expense.Expense, reportCurrency string) ([]expense.Expense, error) {
+	if reportCurrency == "" {
+		return expenses, nil
+	}
+
+	converted := make([]expense.Expense, 0, len(expenses))
+	for _, individualExpense := range expenses {
+		convertedExpense, _, err := exchangeRates.ConvertExpense(individualExpense, reportCurrency)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, convertedExpense)
+	}
+	return converted, nil
+}
@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/pkg/errors"
+)
+
+//requestIDHeader - id of the request, taken from the client when it sends a sane one, echoed in the response and in error bodies
+const requestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+//errorCodes - code of errors which carry no more specific one
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusMethodNotAllowed:    "method_not_allowed",
+	http.StatusConflict:            "conflict",
+	http.StatusUnprocessableEntity: "unprocessable",
+	http.StatusTooManyRequests:     "rate_limited",
+	http.StatusInternalServerError: "internal_error",
+	http.StatusBadGateway:          "bad_gateway",
+	http.StatusServiceUnavailable:  "unavailable",
+	http.StatusGatewayTimeout:      "gateway_timeout",
+}
+
+/*errorResponse - json body of every error response. errors keeps the messages per request field in the shape splitwise uses for create_expense: {"base": ["message"], "cost": ["message"]}*/
+type errorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	//UpstreamStatus - status splitwise answered with when the error comes from splitwise
+	UpstreamStatus int                 `json:"upstream_status,omitempty"`
+	Errors         map[string][]string `json:"errors"`
+}
+
+/*apiError - failure to report to the client*/
+type apiError struct {
+	status         int
+	code           string
+	message        string
+	upstreamStatus int
+	retryAfter     time.Duration
+	fieldErrors    map[string][]string
+}
+
+/*writeJSONError - respond with status and a single base error message*/
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeError(w, apiError{status: status, message: message})
+}
+
+/*writeDecodeError - 422 with the field errors of a body refused while decoding, e.g. out of range users__N__ keys, 400 for any other malformed body*/
+func writeDecodeError(w http.ResponseWriter, prefix string, err error) {
+	if validationErrors, ok := err.(expense.ValidationErrors); ok {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, validationErrors)
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, prefix+err.Error())
+}
+
+/*writeFieldErrors - respond with status and error messages per request field*/
+func writeFieldErrors(w http.ResponseWriter, status int, fieldErrors map[string][]string) {
+	fields := make([]string, 0, len(fieldErrors))
+	for field := range fieldErrors {
+		if field != "base" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	message := strings.Join(fieldErrors["base"], "; ")
+	if message == "" {
+		message = "invalid " + strings.Join(fields, ", ")
+	}
+	code := ""
+	if len(fields) > 0 {
+		code = "validation_failed"
+	}
+	writeError(w, apiError{status: status, code: code, message: message, fieldErrors: fieldErrors})
+}
+
+/*writeSplitwiseError - respond to a failed splitwise call*/
+func writeSplitwiseError(w http.ResponseWriter, err error) {
+	Trace.Println(w.Header().Get(requestIDHeader), err)
+	writeError(w, upstreamError(err))
+}
+
+/*upstreamError - client facing error for a failed splitwise call, upstream auth failures become 401, rate limits 429, outages 502 and timeouts 504*/
+func upstreamError(err error) apiError {
+	cause := errors.Cause(err)
+
+	if splitwiseErr, ok := cause.(*splitwise.Error); ok {
+		apiErr := apiError{message: splitwiseErr.Message, upstreamStatus: splitwiseErr.StatusCode, retryAfter: splitwiseErr.RetryAfter}
+		switch {
+		case splitwiseErr.StatusCode == http.StatusUnauthorized:
+			apiErr.status, apiErr.code = http.StatusUnauthorized, "upstream_unauthorized"
+		case splitwiseErr.StatusCode == http.StatusForbidden:
+			apiErr.status, apiErr.code = http.StatusForbidden, "upstream_forbidden"
+		case splitwiseErr.StatusCode == http.StatusNotFound:
+			apiErr.status, apiErr.code = http.StatusNotFound, "not_found"
+		case splitwiseErr.StatusCode == http.StatusTooManyRequests:
+			apiErr.status, apiErr.code = http.StatusTooManyRequests, "upstream_rate_limited"
+		case splitwiseErr.StatusCode == http.StatusGatewayTimeout:
+			apiErr.status, apiErr.code = http.StatusGatewayTimeout, "upstream_timeout"
+		case splitwiseErr.StatusCode >= 500:
+			apiErr.status, apiErr.code = http.StatusBadGateway, "upstream_unavailable"
+		default:
+			apiErr.status, apiErr.code = http.StatusBadRequest, "upstream_rejected"
+		}
+		return apiErr
+	}
+
+	if netErr, ok := cause.(net.Error); (ok && netErr.Timeout()) || cause == context.DeadlineExceeded {
+		return apiError{status: http.StatusGatewayTimeout, code: "upstream_timeout", message: "splitwise did not answer in time"}
+	}
+	return apiError{status: http.StatusBadGateway, code: "upstream_error", message: "error calling splitwise"}
+}
+
+/*writeError - write apiErr in the error envelope*/
+func writeError(w http.ResponseWriter, apiErr apiError) {
+	if guard, ok := w.(*errorWriter); ok {
+		guard.enveloped = true
+	}
+
+	if apiErr.code == "" {
+		apiErr.code = errorCodes[apiErr.status]
+	}
+	if apiErr.code == "" {
+		apiErr.code = "error"
+	}
+	if apiErr.fieldErrors == nil {
+		apiErr.fieldErrors = map[string][]string{"base": {apiErr.message}}
+	}
+	if apiErr.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((apiErr.retryAfter+time.Second-1)/time.Second)))
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Code:           apiErr.code,
+		Message:        apiErr.message,
+		RequestID:      w.Header().Get(requestIDHeader),
+		UpstreamStatus: apiErr.upstreamStatus,
+		Errors:         apiErr.fieldErrors,
+	})
+}
+
+/*Handle - shared wrapper of every route, tags the request with an id and turns error statuses a handler writes without a body (or with a plain text one) into the error envelope*/
+func Handle(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//routes outside the middleware chain, e.g. not found, still get an id
+		if w.Header().Get(requestIDHeader) == "" {
+			r = withRequestID(w, r)
+		}
+
+		handler(&errorWriter{ResponseWriter: w}, r)
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+/*errorWriter - response writer replacing bare error statuses by the error envelope*/
+type errorWriter struct {
+	http.ResponseWriter
+	status int
+	//enveloped - writeError is writing the response
+	enveloped bool
+	//replaced - the handler's own error body is dropped, it was logged instead
+	replaced bool
+}
+
+func (guard *errorWriter) WriteHeader(status int) {
+	if guard.status != 0 {
+		return
+	}
+	guard.status = status
+	if status < 400 || guard.enveloped {
+		guard.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	guard.enveloped = true
+	guard.replaced = true
+	writeError(guard.ResponseWriter, apiError{status: status, message: strings.ToLower(http.StatusText(status))})
+}
+
+func (guard *errorWriter) Write(contents []byte) (int, error) {
+	if guard.status == 0 {
+		guard.WriteHeader(http.StatusOK)
+	}
+	if guard.replaced {
+		if message := strings.TrimSpace(string(contents)); message != "" {
+			Trace.Println(guard.Header().Get(requestIDHeader), "error response replaced:", message)
+		}
+		return len(contents), nil
+	}
+	return guard.ResponseWriter.Write(contents)
+}
+
+/*Flush - streamed responses still reach the client as they are written*/
+func (guard *errorWriter) Flush() {
+	if flusher, ok := guard.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
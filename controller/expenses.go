@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/gorilla/mux"
+)
+
+/*createExpense - validate request and create it in splitwise*/
+func createExpense(client *splitwise.Client, request *expense.CreateExpenseRequest) ([]expense.Expense, expense.ValidationErrors, error) {
+	group, categories, err := loadGroupAndCategories(client, request.GroupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return createExpenseIn(client, request, group, categories)
+}
+
+/*loadGroupAndCategories - what a create request is validated against, group is nil when it does not exist*/
+func loadGroupAndCategories(client *splitwise.Client, groupID int) (*expense.Group, []expense.Categories, error) {
+	var group *expense.Group
+	if groupID != 0 {
+		var err error
+		group, err = client.GetGroup(groupID)
+		if splitwiseErr, ok := err.(*splitwise.Error); ok && splitwiseErr.StatusCode == http.StatusNotFound {
+			group = nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	categories, err := client.GetCategories()
+	if err != nil {
+		return nil, nil, err
+	}
+	return group, categories, nil
+}
+
+/*createExpenseIn - validate request against an already loaded group and categories and create it*/
+func createExpenseIn(client *splitwise.Client, request *expense.CreateExpenseRequest, group *expense.Group, categories []expense.Categories) ([]expense.Expense, expense.ValidationErrors, error) {
+	if validationErrors := request.Validate(group, categories); validationErrors != nil {
+		return nil, validationErrors, nil
+	}
+
+	expenses, err := client.CreateExpense(request.Params())
+	return expenses, nil, err
+}
+
+/*UpdateExpense - update fields of an existing expense, the updated expense is validated like a new one*/
+func UpdateExpense(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	expenseID, ok := expenseIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	//read request body, the fields of CreateExpense which are present are changed
+	changesByte, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "error reading request body")
+		return
+	}
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(changesByte, &changes); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "request body must be a json object")
+		return
+	}
+	if len(changes) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "nothing to update")
+		return
+	}
+
+	client := requestClient(r)
+	existing, err := client.GetExpense(expenseID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	request := expense.RequestFromExpense(existing)
+	validationErrors, err := request.ApplyChanges(changesByte)
+	if err != nil {
+		writeDecodeError(w, "invalid expense: ", err)
+		return
+	}
+	if validationErrors != nil {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, validationErrors)
+		return
+	}
+
+	group, categories, err := loadGroupAndCategories(client, request.GroupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	if validationErrors := request.Validate(group, categories); validationErrors != nil {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, validationErrors)
+		return
+	}
+
+	expenses, err := client.UpdateExpense(expenseID, request.Params())
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	expenseMirror.MarkStale(sessionVals.user)
+
+	//send updated expense
+	contentJSON, err := json.Marshal(expense.ExpensesWrapper{Expenses: expenses})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Write(contentJSON)
+}
+
+/*DeleteExpense - delete an expense, it can be restored with RestoreExpense*/
+func DeleteExpense(w http.ResponseWriter, r *http.Request) {
+	changeExpenseDeletion(w, r, false)
+}
+
+/*RestoreExpense - restore a deleted expense*/
+func RestoreExpense(w http.ResponseWriter, r *http.Request) {
+	changeExpenseDeletion(w, r, true)
+}
+
+func changeExpenseDeletion(w http.ResponseWriter, r *http.Request, restore bool) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	expenseID, ok := expenseIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	client := requestClient(r)
+	var err error
+	if restore {
+		err = client.RestoreExpense(expenseID)
+	} else {
+		err = client.DeleteExpense(expenseID)
+	}
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	expenseMirror.MarkStale(sessionVals.user)
+
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+/*expenseIDFromRequest - {id} route variable, writes a 400 when it is not a valid id*/
+func expenseIDFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	expenseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || expenseID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid expense id")
+		return 0, false
+	}
+	return expenseID, true
+}
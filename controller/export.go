@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"splitwiseAngularAPI/export"
+	"splitwiseAngularAPI/splitwise"
+)
+
+/*ExportGroupData - the expenses of GetGroupData as a spreadsheet, ?format=csv|xlsx*/
+func ExportGroupData(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	defer r.Body.Close()
+	q := r.URL.Query()
+	groupID, err := strconv.Atoi(q.Get("groupID"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		writeJSONError(w, http.StatusBadRequest, "format must be csv or xlsx")
+		return
+	}
+	excludePayments := false
+	if value := q.Get("excludePayments"); value != "" {
+		excludePayments, err = strconv.ParseBool(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "excludePayments must be true or false")
+			return
+		}
+	}
+	startDate, endDate, err := getStartAndEndDate(q, sessionVals)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client := requestClient(r)
+	group, err := client.GetGroup(groupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	expenses, err := mirroredExpenses(w, r, splitwise.ExpensesQuery{
+		GroupID:     groupID,
+		DatedAfter:  startDate,
+		DatedBefore: endDate,
+	})
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	expenses = expensesInLocation(expenses, startDate.Location())
+
+	//oldest first reads better in a ledger
+	for left, right := 0, len(expenses)-1; left < right; left, right = left+1, right-1 {
+		expenses[left], expenses[right] = expenses[right], expenses[left]
+	}
+	if excludePayments {
+		nonPayments := expenses[:0]
+		for _, individualExpense := range expenses {
+			if !individualExpense.Payment {
+				nonPayments = append(nonPayments, individualExpense)
+			}
+		}
+		expenses = nonPayments
+	}
+
+	table, err := export.ExpenseTable(expenses, group.Members)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusBadGateway, "splitwise returned an invalid amount")
+		return
+	}
+
+	fileName := fmt.Sprintf("expenses-%d-%s-%s.%s", groupID, startDate.Format("20060102"), endDate.AddDate(0, 0, -1).Format("20060102"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+
+	if format == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = export.WriteXLSX(w, table)
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = export.WriteCSV(w, table)
+	}
+	if err != nil {
+		Trace.Println("error writing export", err)
+	}
+}
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/splitwise"
+	"splitwiseAngularAPI/statement"
+)
+
+/*importPreviewRequest - csv text of a bank statement and how to read it*/
+type importPreviewRequest struct {
+	GroupID      int               `json:"group_id"`
+	CurrencyCode string            `json:"currency_code"`
+	Mapping      statement.Mapping `json:"mapping"`
+	CSV          string            `json:"csv"`
+}
+
+/*importPreviewRow - a parsed line with the expense that would be created for it, credits get none*/
+type importPreviewRow struct {
+	statement.Row
+	Expense      *expense.CreateExpenseRequest `json:"expense,omitempty"`
+	CategoryName string                        `json:"category_name,omitempty"`
+	//RuleID - categorization rule which chose the category
+	RuleID      int `json:"rule_id,omitempty"`
+	DuplicateOf int `json:"duplicate_of,omitempty"`
+}
+
+/*importPreview - response of ImportPreview*/
+type importPreview struct {
+	GroupID int                `json:"group_id"`
+	Rows    []importPreviewRow `json:"rows"`
+}
+
+/*importConfirmRequest - rows of a preview, possibly edited, to create*/
+type importConfirmRequest struct {
+	GroupID int `json:"group_id"`
+	Rows    []struct {
+		Line           int                          `json:"line"`
+		Expense        expense.CreateExpenseRequest `json:"expense"`
+		AllowDuplicate bool                         `json:"allow_duplicate"`
+	} `json:"rows"`
+}
+
+/*importResult - outcome of creating a single row*/
+type importResult struct {
+	Line        int                      `json:"line"`
+	Success     bool                     `json:"success"`
+	ExpenseID   int                      `json:"expense_id,omitempty"`
+	DuplicateOf int                      `json:"duplicate_of,omitempty"`
+	Errors      expense.ValidationErrors `json:"errors,omitempty"`
+}
+
+/*importConfirmation - response of ImportConfirm*/
+type importConfirmation struct {
+	GroupID int            `json:"group_id"`
+	Created int            `json:"created"`
+	Failed  int            `json:"failed"`
+	Results []importResult `json:"results"`
+}
+
+/*ImportPreview - parse a bank statement csv into suggested expenses, nothing is created*/
+func ImportPreview(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	//read request body
+	var request importPreviewRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		writeDecodeError(w, "invalid import: ", err)
+		return
+	}
+	if request.GroupID == 0 {
+		writeJSONError(w, http.StatusBadRequest, "group_id is required")
+		return
+	}
+
+	client := requestClient(r)
+	user, err := client.GetCurrentUser()
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	currencyCode := strings.ToUpper(request.CurrencyCode)
+	if currencyCode == "" {
+		currencyCode = user.DefaultCurrency
+	}
+	if !expense.IsCurrencyCode(currencyCode) {
+		writeJSONError(w, http.StatusBadRequest, "currency_code must be a three letter ISO currency code")
+		return
+	}
+
+	rows, err := statement.Parse(strings.NewReader(request.CSV), request.Mapping, currencyCode)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	group, err := client.GetGroup(request.GroupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	history, err := groupHistory(client, request.GroupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	preview := importPreview{GroupID: request.GroupID, Rows: make([]importPreviewRow, 0, len(rows))}
+	for _, row := range rows {
+		previewRow := importPreviewRow{Row: row}
+		if row.Error == "" && !row.Credit {
+			previewRow.Expense = &expense.CreateExpenseRequest{
+				Cost:         row.Amount.String(),
+				CurrencyCode: currencyCode,
+				Description:  row.Description,
+				Date:         row.Date.Format("2006-01-02"),
+				GroupID:      request.GroupID,
+				Users:        statement.EqualSplit(row.Amount, user.ID, group.Members),
+			}
+			if rule, ok := applyCategoryRules(sessionVals.user, previewRow.Expense); ok {
+				previewRow.RuleID = rule.ID
+			} else if category, ok := statement.SuggestCategory(history, row.Description); ok {
+				previewRow.Expense.CategoryID = category.ID
+				previewRow.CategoryName = category.Name
+			}
+			previewRow.DuplicateOf = statement.FindDuplicate(history, row.Date, row.Amount)
+		}
+		preview.Rows = append(preview.Rows, previewRow)
+	}
+
+	//send preview
+	contentJSON, err := json.Marshal(preview)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Write(contentJSON)
+}
+
+/*ImportConfirm - create the confirmed rows of a preview, one result per row*/
+func ImportConfirm(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	//read request body
+	var request importConfirmRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		writeDecodeError(w, "invalid import: ", err)
+		return
+	}
+	if request.GroupID == 0 {
+		writeJSONError(w, http.StatusBadRequest, "group_id is required")
+		return
+	}
+
+	client := requestClient(r)
+	group, categories, err := loadGroupAndCategories(client, request.GroupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	if group == nil {
+		writeJSONError(w, http.StatusNotFound, "group not found")
+		return
+	}
+	//check again, the group may have changed since the preview
+	history, err := groupHistory(client, request.GroupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	confirmation := importConfirmation{GroupID: request.GroupID, Results: make([]importResult, 0, len(request.Rows))}
+	for _, row := range request.Rows {
+		result := importResult{Line: row.Line}
+		createRequest := row.Expense
+		createRequest.GroupID = request.GroupID
+		applyCategoryRules(sessionVals.user, &createRequest)
+
+		if !row.AllowDuplicate {
+			result.DuplicateOf = duplicateOfRequest(history, &createRequest)
+		}
+		if result.DuplicateOf != 0 {
+			result.Errors = expense.ValidationErrors{"base": {"duplicate of an existing expense"}}
+		} else {
+			expenses, validationErrors, err := createExpenseIn(client, &createRequest, group, categories)
+			switch {
+			case validationErrors != nil:
+				result.Errors = validationErrors
+			case err != nil:
+				Trace.Println(err)
+				result.Errors = expense.ValidationErrors{"base": {importErrorMessage(err)}}
+			default:
+				result.Success = true
+				if len(expenses) > 0 {
+					result.ExpenseID = expenses[0].ID
+				}
+				//a repeated line later in the same statement is a duplicate too
+				history = append(history, expenses...)
+			}
+		}
+
+		if result.Success {
+			confirmation.Created++
+		} else {
+			confirmation.Failed++
+		}
+		confirmation.Results = append(confirmation.Results, result)
+	}
+
+	if confirmation.Created > 0 {
+		expenseMirror.MarkStale(sessionVals.user)
+	}
+
+	//send results
+	contentJSON, err := json.Marshal(confirmation)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Write(contentJSON)
+}
+
+/*groupHistory - every expense of the group, used for duplicates and category suggestions*/
+func groupHistory(client *splitwise.Client, groupID int) ([]expense.Expense, error) {
+	return client.GetExpenses(splitwise.ExpensesQuery{GroupID: groupID})
+}
+
+/*duplicateOfRequest - FindDuplicate for a create request, 0 when it cannot be compared*/
+func duplicateOfRequest(history []expense.Expense, request *expense.CreateExpenseRequest) int {
+	cost, err := expense.ParseMoney(request.Cost, request.CurrencyCode)
+	if err != nil {
+		return 0
+	}
+	date, err := expense.ParseDate(request.Date)
+	if err != nil {
+		return 0
+	}
+	return statement.FindDuplicate(history, date, cost)
+}
+
+/*importErrorMessage - splitwise's message for a failed row*/
+func importErrorMessage(err error) string {
+	if splitwiseErr, ok := err.(*splitwise.Error); ok {
+		return splitwiseErr.Message
+	}
+	return "could not create expense"
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+//requestTimeout - splitwise calls of a request still running after this are cancelled, a single call gives up earlier
+const requestTimeout = time.Minute
+
+type contextKey int
+
+const (
+	requestInfoKey contextKey = iota
+	sessionKey
+)
+
+/*requestInfo - request facts shared by the middlewares, user is filled in once the session is resolved*/
+type requestInfo struct {
+	id   string
+	user string
+}
+
+/*requestInfoOf - the request's info, an empty one outside the middleware chain*/
+func requestInfoOf(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+/*RequestID - tag the request with the client's X-Request-ID when it is sane or a new one, echoed in the response*/
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, withRequestID(w, r))
+	})
+}
+
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	requestID := r.Header.Get(requestIDHeader)
+	if !validRequestID.MatchString(requestID) {
+		requestID = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, requestID)
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey, &requestInfo{id: requestID}))
+}
+
+/*AccessLog - one log line per request with status, size and duration*/
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		info := requestInfoOf(r)
+		Trace.Printf("%s %s %d %dB %s request=%s user=%s", r.Method, r.URL.Path, recorder.status, recorder.size, time.Since(started).Round(time.Millisecond), info.id, info.user)
+	})
+}
+
+/*Recover - log the stack of a panicking handler and answer with a json 500 instead of dropping the connection*/
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			//the server aborts such responses on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			Trace.Printf("panic serving %s %s request=%s: %v\n%s", r.Method, r.URL.Path, requestInfoOf(r).id, recovered, debug.Stack())
+			if recorder.status == 0 {
+				writeJSONError(w, http.StatusInternalServerError, "internal error")
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+/*Timeout - cancel the request's context after requestTimeout, splitwise calls still running then fail with a 504. the response is not buffered so streamed exports keep flushing*/
+func Timeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/*Authenticate - resolve the session once and put it in the request context, 401 without one. OPTIONS requests pass so handlers can answer them before a session exists*/
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session := validateSessionAndGetUser(r)
+		if session == nil {
+			writeJSONError(w, http.StatusUnauthorized, "not logged in")
+			return
+		}
+		requestInfoOf(r).user = session.user
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey, session)))
+	})
+}
+
+/*requestSession - session Authenticate resolved for the request*/
+func requestSession(r *http.Request) *sessionValues {
+	session, _ := r.Context().Value(sessionKey).(*sessionValues)
+	return session
+}
+
+/*statusRecorder - remembers the status and size of a response*/
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(contents []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	size, err := recorder.ResponseWriter.Write(contents)
+	recorder.size += size
+	return size, err
+}
+
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutStreamsResponses(t *testing.T) {
+	release := make(chan struct{})
+	handler := Timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if deadline, ok := r.Context().Deadline(); !ok || time.Until(deadline) > requestTimeout {
+			t.Errorf("handler context has no deadline within %s", requestTimeout)
+		}
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("second\n"))
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	//the first line arrives while the handler is still running
+	lines := make(chan string)
+	reader := bufio.NewReader(response.Body)
+	go func() {
+		line, _ := reader.ReadString('\n')
+		lines <- line
+	}()
+	select {
+	case line := <-lines:
+		if line != "first\n" {
+			t.Errorf("first line = %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("flushed line was buffered until the handler finished")
+	}
+	close(release)
+
+	if line, _ := reader.ReadString('\n'); line != "second\n" {
+		t.Errorf("second line = %q", line)
+	}
+}
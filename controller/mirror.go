@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/mirror"
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/pkg/errors"
+)
+
+//mirrorMaxAge - a mirror synced longer ago is refreshed before it is served
+const mirrorMaxAge = time.Minute
+
+//mirrorSyncWait - how long a request waits for a refresh before it is served from the stale mirror
+const mirrorSyncWait = 2 * time.Second
+
+//mirrorSyncInterval - how often the mirrors of logged in users are refreshed in the background
+const mirrorSyncInterval = 5 * time.Minute
+
+//dataSourceHeader, mirrorSyncedAtHeader, mirrorStaleHeader - tell the client where GetGroupData came from and how old it is
+const dataSourceHeader = "X-Data-Source"
+const mirrorSyncedAtHeader = "X-Mirror-Synced-At"
+const mirrorStaleHeader = "X-Mirror-Stale"
+
+//expenseMirror - local copy of the groups and expenses of logged in users
+var expenseMirror = mirror.NewMemory()
+
+/*syncMirror - refresh the user's mirror, logging failures*/
+func syncMirror(user string, client *splitwise.Client) error {
+	result, err := expenseMirror.Sync(user, client)
+	if err != nil {
+		Trace.Println("error syncing mirror of user", user, err)
+		return err
+	}
+	Trace.Println("synced mirror of user", user, "full", result.Full, "updated", result.Updated, "deleted", result.Deleted)
+	return nil
+}
+
+/*syncMirrors - periodically refresh the mirrors of users who are still logged in*/
+func syncMirrors() {
+	for range time.Tick(mirrorSyncInterval) {
+		for _, user := range expenseMirror.Users() {
+			session, err := sessionStore.Get(user)
+			if err != nil || session == nil || session.expired(time.Now()) {
+				continue
+			}
+			syncMirror(user, newSplitwiseClient(user, session.token))
+		}
+	}
+}
+
+/*mirroredExpenses - expenses matching query from the mirror of the request's user, refreshed first when it is stale, splitwise is asked directly while the user has no mirror yet. A refresh rejected as unauthorized is returned instead of the mirror*/
+func mirroredExpenses(w http.ResponseWriter, r *http.Request, query splitwise.ExpensesQuery) ([]expense.Expense, error) {
+	session := requestSession(r)
+
+	if freshness, ok := expenseMirror.Freshness(session.user, mirrorMaxAge); !ok || freshness.Stale {
+		//the sync may outlive the request, it is not cancelled with it
+		done := make(chan error, 1)
+		go func() { done <- syncMirror(session.user, newSplitwiseClient(session.user, session.token)) }()
+		select {
+		case err := <-done:
+			//a revoked token must not be hidden behind the mirror
+			if splitwiseErr, ok := errors.Cause(err).(*splitwise.Error); ok && splitwiseErr.StatusCode == http.StatusUnauthorized {
+				return nil, err
+			}
+		case <-time.After(mirrorSyncWait):
+			Trace.Println("mirror sync of user", session.user, "is slow, serving the mirror as it is")
+		}
+	}
+
+	freshness, ok := expenseMirror.Freshness(session.user, mirrorMaxAge)
+	if !ok {
+		w.Header().Set(dataSourceHeader, "splitwise")
+		return requestClient(r).GetExpenses(query)
+	}
+
+	w.Header().Set(dataSourceHeader, "mirror")
+	w.Header().Set(mirrorSyncedAtHeader, freshness.SyncedAt.UTC().Format(time.RFC3339))
+	w.Header().Set(mirrorStaleHeader, strconv.FormatBool(freshness.Stale))
+
+	expenses := expenseMirror.Expenses(session.user, query.GroupID, query.DatedAfter, query.DatedBefore)
+	if query.Offset > len(expenses) {
+		return []expense.Expense{}, nil
+	}
+	expenses = expenses[query.Offset:]
+	if query.Limit > 0 && len(expenses) > query.Limit {
+		expenses = expenses[:query.Limit]
+	}
+	return expenses, nil
+}
+
+/*GetSyncStatus - freshness of the user's mirror, ?sync=true refreshes it first*/
+func GetSyncStatus(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	if value := r.URL.Query().Get("sync"); value != "" {
+		syncNow, err := strconv.ParseBool(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "sync must be true or false")
+			return
+		}
+		if syncNow {
+			if err := syncMirror(sessionVals.user, requestClient(r)); err != nil {
+				writeSplitwiseError(w, err)
+				return
+			}
+		}
+	}
+
+	freshness, synced := expenseMirror.Freshness(sessionVals.user, mirrorMaxAge)
+	contentJSON, err := json.Marshal(struct {
+		Synced bool `json:"synced"`
+		mirror.Freshness
+	}{synced, freshness})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
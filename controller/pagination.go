@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//defaultCursorPageSize, maxCursorPageSize - expenses per page of a cursor paged response
+const defaultCursorPageSize = 50
+const maxCursorPageSize = 500
+
+//nextCursorHeader - response header holding the cursor of the next page, absent on the last page
+const nextCursorHeader = "X-Next-Cursor"
+
+/*expenseCursor - position in the expenses of a group and date range, opaque to the client*/
+type expenseCursor struct {
+	GroupID int   `json:"g"`
+	Start   int64 `json:"s"`
+	End     int64 `json:"e"`
+	Offset  int   `json:"o"`
+}
+
+/*pageFromQuery - ?pageSize= and ?cursor=, paged is false when neither is set and everything is returned*/
+func pageFromQuery(q url.Values, groupID int, startDate time.Time, endDate time.Time) (cursor expenseCursor, pageSize int, paged bool, err error) {
+	cursor = expenseCursor{GroupID: groupID, Start: startDate.Unix(), End: endDate.Unix()}
+	if q.Get("pageSize") == "" && q.Get("cursor") == "" {
+		return cursor, 0, false, nil
+	}
+
+	pageSize = defaultCursorPageSize
+	if value := q.Get("pageSize"); value != "" {
+		pageSize, err = strconv.Atoi(value)
+		if err != nil || pageSize < 1 || pageSize > maxCursorPageSize {
+			return cursor, 0, false, errors.Errorf("pageSize must be between 1 and %d", maxCursorPageSize)
+		}
+	}
+
+	if value := q.Get("cursor"); value != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(value)
+		var requested expenseCursor
+		if err != nil || json.Unmarshal(decoded, &requested) != nil || requested.Offset < 0 {
+			return cursor, 0, false, errors.New("invalid cursor")
+		}
+		if requested.GroupID != cursor.GroupID || requested.Start != cursor.Start || requested.End != cursor.End {
+			return cursor, 0, false, errors.New("cursor belongs to another group or date range")
+		}
+		cursor.Offset = requested.Offset
+	}
+	return cursor, pageSize, true, nil
+}
+
+/*encode - the cursor as the url safe string clients send back*/
+func (cursor expenseCursor) encode() string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
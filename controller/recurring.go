@@ -0,0 +1,341 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/recurring"
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/dghubble/oauth1"
+	"github.com/gorilla/mux"
+)
+
+//recurringTick - how often the scheduler looks for due recurring expenses
+const recurringTick = 15 * time.Minute
+
+//maxRunAttempts - failed runs are retried on later ticks until this many attempts
+const maxRunAttempts = 3
+
+//runLease - a pending run younger than this is being posted by another replica and not claimed again
+const runLease = 5 * time.Minute
+
+//maxCatchUp - most due dates of a single template posted after downtime
+const maxCatchUp = 24
+
+//recurringExpenses - templates, run history and owner tokens of the scheduler
+var recurringExpenses = recurring.NewMemoryStore()
+
+/*recurringRequest - body of CreateRecurringExpense*/
+type recurringRequest struct {
+	Name     string                       `json:"name"`
+	Schedule recurring.Schedule           `json:"schedule"`
+	Expense  expense.CreateExpenseRequest `json:"expense"`
+}
+
+/*scheduleRecurring - post due recurring expenses now and on every tick*/
+func scheduleRecurring() {
+	runDueRecurring(time.Now())
+	for now := range time.Tick(recurringTick) {
+		runDueRecurring(now)
+	}
+}
+
+/*runDueRecurring - post every due date of every template which has no successful run yet*/
+func runDueRecurring(now time.Time) {
+	templates, err := recurringExpenses.Templates("")
+	if err != nil {
+		Trace.Println("error loading recurring expenses", err)
+		return
+	}
+	for _, template := range templates {
+		due := template.Due(now)
+		if len(due) > maxCatchUp {
+			Trace.Printf("recurring expense %d has %d due dates, posting the last %d", template.ID, len(due), maxCatchUp)
+			due = due[len(due)-maxCatchUp:]
+		}
+		for _, date := range due {
+			runRecurring(template, date, now)
+		}
+	}
+}
+
+/*runRecurring - post template's expense for date unless a run for it already posted, gave up or is being posted by another replica*/
+func runRecurring(template recurring.Template, date time.Time, now time.Time) {
+	credentials, ok, err := recurringExpenses.Credentials(template.Owner)
+	if err != nil || !ok {
+		Trace.Println("no token for owner of recurring expense", template.ID, err)
+		return
+	}
+
+	//record the attempt before posting so neither a restart nor another replica posts twice
+	run, previous, claimed, err := recurringExpenses.ClaimRun(recurring.Run{Key: template.RunKey(date), TemplateID: template.ID,
+		Owner: template.Owner, ScheduledFor: date}, now, maxRunAttempts, runLease)
+	if err != nil {
+		Trace.Println("error claiming recurring run", template.RunKey(date), err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	client := newSplitwiseClient(template.Owner, oauth1.NewToken(credentials.Token, credentials.TokenSecret))
+
+	request := template.Expense
+	request.Date = date.Format("2006-01-02")
+
+	//a pending run was interrupted while posting and a failed one may have timed out after splitwise
+	//created the expense, check before posting again. invalid expenses are never retried
+	if previous.Status == recurring.RunPending || previous.Status == recurring.RunFailed {
+		expenseID, err := findPostedExpense(client, &request, date)
+		if err != nil {
+			Trace.Println("error checking earlier attempt of run", run.Key, err)
+			saveRun(previous)
+			return
+		}
+		if expenseID != 0 {
+			run.Status = recurring.RunSucceeded
+			run.Attempts = previous.Attempts
+			run.ExpenseID = expenseID
+			run.Error = ""
+			saveRun(run)
+			return
+		}
+	}
+
+	applyCategoryRules(template.Owner, &request)
+	expenses, validationErrors, err := createExpense(client, &request)
+	switch {
+	case validationErrors != nil:
+		run.Status = recurring.RunFailed
+		run.Attempts = maxRunAttempts
+		fields := make([]string, 0, len(validationErrors))
+		for field, messages := range validationErrors {
+			fields = append(fields, field+" "+strings.Join(messages, ", "))
+		}
+		sort.Strings(fields)
+		run.Error = "invalid expense: " + strings.Join(fields, "; ")
+	case err != nil:
+		run.Status = recurring.RunFailed
+		run.Error = err.Error()
+	default:
+		run.Status = recurring.RunSucceeded
+		run.Error = ""
+		if len(expenses) > 0 {
+			run.ExpenseID = expenses[0].ID
+		}
+		expenseMirror.MarkStale(template.Owner)
+	}
+	saveRun(run)
+}
+
+func saveRun(run recurring.Run) {
+	if err := recurringExpenses.SaveRun(run); err != nil {
+		Trace.Println("error saving recurring run", run.Key, err)
+	}
+}
+
+/*findPostedExpense - id of a not deleted expense in the group on request's date with the request's description and cost*/
+func findPostedExpense(client *splitwise.Client, request *expense.CreateExpenseRequest, date time.Time) (int, error) {
+	if _, err := expense.ParseMoney(request.Cost, request.CurrencyCode); err != nil {
+		return 0, err
+	}
+	//date is midnight in the owner's time zone while splitwise may keep the posted date in UTC
+	expenses, err := client.GetExpenses(splitwise.ExpensesQuery{
+		GroupID:     request.GroupID,
+		DatedAfter:  date.AddDate(0, 0, -1),
+		DatedBefore: date.AddDate(0, 0, 2),
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, individualExpense := range expenses {
+		if !individualExpense.DeletedAt.IsZero() || individualExpense.Description != request.Description {
+			continue
+		}
+		if individualExpense.Date.UTC().Format("2006-01-02") != request.Date &&
+			individualExpense.Date.In(date.Location()).Format("2006-01-02") != request.Date {
+			continue
+		}
+		//without a currency code splitwise posts in the user's default currency
+		currency := request.CurrencyCode
+		if currency == "" {
+			currency = individualExpense.CurrencyCode
+		}
+		if !strings.EqualFold(currency, individualExpense.CurrencyCode) {
+			continue
+		}
+		cost, err := expense.ParseMoney(request.Cost, currency)
+		if err != nil {
+			continue
+		}
+		posted, err := expense.ParseMoney(individualExpense.Cost, currency)
+		if err == nil && posted.Minor == cost.Minor {
+			return individualExpense.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+/*GetRecurringExpenses - recurring expense templates of the current user*/
+func GetRecurringExpenses(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	templates, err := recurringExpenses.Templates(sessionVals.user)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error loading recurring expenses")
+		return
+	}
+	contentJSON, err := json.Marshal(map[string][]recurring.Template{"templates": templates})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
+
+/*CreateRecurringExpense - save a template, the scheduler posts it with the current user's token*/
+func CreateRecurringExpense(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	//read request body
+	var request recurringRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		writeDecodeError(w, "invalid recurring expense: ", err)
+		return
+	}
+	if err := request.Schedule.Validate(); err != nil {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, map[string][]string{"schedule": {err.Error()}})
+		return
+	}
+
+	//validate the expense as it would be posted on the first date
+	client := requestClient(r)
+	group, categories, err := loadGroupAndCategories(client, request.Expense.GroupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	request.Expense.Date = request.Schedule.StartDate
+	if validationErrors := request.Expense.Validate(group, categories); validationErrors != nil {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, validationErrors)
+		return
+	}
+	request.Expense.Date = ""
+
+	if request.Schedule.Interval == 0 {
+		request.Schedule.Interval = 1
+	}
+	name := request.Name
+	if name == "" {
+		name = request.Expense.Description
+	}
+	template, err := recurringExpenses.AddTemplate(recurring.Template{
+		Owner:     sessionVals.user,
+		Name:      name,
+		Schedule:  request.Schedule,
+		Expense:   request.Expense,
+		CreatedAt: time.Now().UTC(),
+		TimeZone:  sessionVals.timeZone,
+	}, recurring.Credentials{Token: sessionVals.token.Token, TokenSecret: sessionVals.token.TokenSecret})
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error saving recurring expense")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+/*DeleteRecurringExpense - stop posting template {id}, its run history is kept*/
+func DeleteRecurringExpense(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	templateID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid recurring expense id")
+		return
+	}
+	deleted, err := recurringExpenses.DeleteTemplate(sessionVals.user, templateID)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error deleting recurring expense")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "recurring expense not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+/*GetRecurringRuns - run history of the current user's templates, ?templateID= for one template*/
+func GetRecurringRuns(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	templateID := 0
+	if value := r.URL.Query().Get("templateID"); value != "" {
+		var err error
+		templateID, err = strconv.Atoi(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid templateID")
+			return
+		}
+	}
+
+	runs, err := recurringExpenses.Runs(sessionVals.user, templateID)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error loading recurring runs")
+		return
+	}
+	contentJSON, err := json.Marshal(map[string][]recurring.Run{"runs": runs})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
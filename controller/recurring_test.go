@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/recurring"
+	"splitwiseAngularAPI/splitwisefake"
+
+	"github.com/dghubble/oauth1"
+)
+
+/*recurringTemplate - log userID in and store a monthly template without currency code owned by them*/
+func recurringTemplate(t *testing.T, testAPI *testAPI, userID int) recurring.Template {
+	owner, err := testAPI.newLoginFlow().login(testAPI, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := sessionStore.Get(owner)
+	if err != nil || session == nil {
+		t.Fatalf("no session for user %d: %v", userID, err)
+	}
+
+	recurringExpenses = recurring.NewMemoryStore()
+	template, err := recurringExpenses.AddTemplate(recurring.Template{
+		Owner:    owner,
+		Name:     "Internet",
+		Schedule: recurring.Schedule{Frequency: "monthly", DayOfMonth: 1, StartDate: "2019-05-01"},
+		Expense: expense.CreateExpenseRequest{
+			Cost:        "60",
+			Description: "Internet",
+			GroupID:     splitwisefake.ApartmentGroupID,
+			Users: []expense.ExpenseShare{
+				{UserID: splitwisefake.AliceID, PaidShare: "60", OwedShare: "30"},
+				{UserID: splitwisefake.BobID, OwedShare: "30"},
+			},
+		},
+		CreatedAt: time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC),
+	}, recurring.Credentials{Token: session.token.Token, TokenSecret: session.token.TokenSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+/*postedFor - not deleted expenses of the fake with the template's description on date*/
+func postedFor(fake *splitwisefake.Server, template recurring.Template, date time.Time) []int {
+	var ids []int
+	for _, individualExpense := range fake.Expenses() {
+		if individualExpense.DeletedAt.IsZero() && individualExpense.Description == template.Expense.Description &&
+			individualExpense.Date.Format("2006-01-02") == date.Format("2006-01-02") {
+			ids = append(ids, individualExpense.ID)
+		}
+	}
+	return ids
+}
+
+func TestRecurringRetryFindsPostedExpense(t *testing.T) {
+	for _, status := range []string{recurring.RunPending, recurring.RunFailed} {
+		t.Run(status, func(t *testing.T) {
+			testAPI := newTestAPI(t)
+			template := recurringTemplate(t, testAPI, splitwisefake.AliceID)
+			date := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+
+			//the earlier attempt reached splitwise but its answer was lost
+			credentials, _, _ := recurringExpenses.Credentials(template.Owner)
+			client := newSplitwiseClient(template.Owner, oauth1.NewToken(credentials.Token, credentials.TokenSecret))
+			request := template.Expense
+			request.Date = date.Format("2006-01-02")
+			posted, validationErrors, err := createExpense(client, &request)
+			if err != nil || validationErrors != nil {
+				t.Fatal(err, validationErrors)
+			}
+			saveRun(recurring.Run{Key: template.RunKey(date), TemplateID: template.ID, Owner: template.Owner,
+				ScheduledFor: date, Status: status, Attempts: 1, Error: "timeout"})
+
+			runRecurring(template, date, date)
+
+			if ids := postedFor(testAPI.fake, template, date); len(ids) != 1 {
+				t.Errorf("expense was posted %d times", len(ids))
+			}
+			run, _, _ := recurringExpenses.Run(template.RunKey(date))
+			if run.Status != recurring.RunSucceeded || run.ExpenseID != posted[0].ID {
+				t.Errorf("run = %+v, want succeeded with expense %d", run, posted[0].ID)
+			}
+		})
+	}
+}
+
+func TestRecurringRetryPostsMissingExpense(t *testing.T) {
+	testAPI := newTestAPI(t)
+	template := recurringTemplate(t, testAPI, splitwisefake.AliceID)
+	date := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	saveRun(recurring.Run{Key: template.RunKey(date), TemplateID: template.ID, Owner: template.Owner,
+		ScheduledFor: date, Status: recurring.RunFailed, Attempts: 1, Error: "connection refused"})
+
+	runRecurring(template, date, date)
+	runRecurring(template, date, date)
+
+	if ids := postedFor(testAPI.fake, template, date); len(ids) != 1 {
+		t.Errorf("expense was posted %d times, want once", len(ids))
+	}
+	if run, _, _ := recurringExpenses.Run(template.RunKey(date)); run.Status != recurring.RunSucceeded || run.Attempts != 2 {
+		t.Errorf("run = %+v, want succeeded on the second attempt", run)
+	}
+}
+
+func TestFindPostedExpenseComparesInPostedCurrency(t *testing.T) {
+	testAPI := newTestAPI(t)
+	template := recurringTemplate(t, testAPI, splitwisefake.AliceID)
+	date := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	credentials, _, _ := recurringExpenses.Credentials(template.Owner)
+	client := newSplitwiseClient(template.Owner, oauth1.NewToken(credentials.Token, credentials.TokenSecret))
+
+	request := template.Expense
+	request.Date = date.Format("2006-01-02")
+	if _, _, err := createExpense(client, &request); err != nil {
+		t.Fatal(err)
+	}
+
+	//splitwise stores the user's default currency and its own formatting of the cost
+	request.Cost = "60.00"
+	if expenseID, err := findPostedExpense(client, &request, date); err != nil || expenseID == 0 {
+		t.Errorf("expense without currency code not found: %d, %v", expenseID, err)
+	}
+	request.CurrencyCode = "USD"
+	if expenseID, err := findPostedExpense(client, &request, date); err != nil || expenseID == 0 {
+		t.Errorf("expense in USD not found: %d, %v", expenseID, err)
+	}
+	request.CurrencyCode = "EUR"
+	if expenseID, err := findPostedExpense(client, &request, date); err != nil || expenseID != 0 {
+		t.Errorf("expense in USD matched a EUR request: %d, %v", expenseID, err)
+	}
+}
+
+func TestRecurringRetryFindsPostedExpenseInOwnersTimeZone(t *testing.T) {
+	testAPI := newTestAPI(t)
+	template := recurringTemplate(t, testAPI, splitwisefake.AliceID)
+	template.TimeZone = "America/New_York"
+	date := template.Due(time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC))[0]
+
+	credentials, _, _ := recurringExpenses.Credentials(template.Owner)
+	client := newSplitwiseClient(template.Owner, oauth1.NewToken(credentials.Token, credentials.TokenSecret))
+	request := template.Expense
+	request.Date = date.Format("2006-01-02")
+	if _, _, err := createExpense(client, &request); err != nil {
+		t.Fatal(err)
+	}
+	saveRun(recurring.Run{Key: template.RunKey(date), TemplateID: template.ID, Owner: template.Owner,
+		ScheduledFor: date, Status: recurring.RunFailed, Attempts: 1, Error: "timeout"})
+
+	runRecurring(template, date, date)
+
+	if ids := postedFor(testAPI.fake, template, date); len(ids) != 1 {
+		t.Errorf("expense was posted %d times", len(ids))
+	}
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"splitwiseAngularAPI/filelock"
+
+	"github.com/pkg/errors"
+)
+
+//requestTokenTTL - how long a login may take between IndexHandler and CompleteAuth
+const requestTokenTTL = 10 * time.Minute
+
+type pendingRequestToken struct {
+	Secret    string    `json:"secret"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+/*requestTokenStore - oauth1 request secrets of logins in progress keyed by request token. With a path the secrets are kept in that file under an os lock, so a login started on one replica can complete on another*/
+type requestTokenStore struct {
+	mutex   sync.Mutex
+	pending map[string]pendingRequestToken
+	path    string
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+func newRequestTokenStore(ttl time.Duration) *requestTokenStore {
+	return &requestTokenStore{
+		pending: make(map[string]pendingRequestToken),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+func newFileRequestTokenStore(path string, ttl time.Duration) (*requestTokenStore, error) {
+	store := newRequestTokenStore(ttl)
+	store.path = path
+
+	//make sure the file is readable before accepting logins
+	err := filelock.Locked(path, false, func() error {
+		_, err := store.load()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+/*requestTokenStoreFor - keep logins in progress where the session store selected in configuration keeps sessions*/
+func requestTokenStoreFor(conf *Configuration) (*requestTokenStore, error) {
+	if conf.SessionStore == "file" {
+		return newFileRequestTokenStore(conf.SessionStorePath+".logins", requestTokenTTL)
+	}
+	return newRequestTokenStore(requestTokenTTL), nil
+}
+
+/*put - remember the secret for a request token, also drops abandoned logins*/
+func (store *requestTokenStore) put(requestToken string, requestSecret string) error {
+	return store.update(func(pending map[string]pendingRequestToken) {
+		now := store.now()
+		for token, login := range pending {
+			if !login.ExpiresAt.After(now) {
+				delete(pending, token)
+			}
+		}
+		pending[requestToken] = pendingRequestToken{Secret: requestSecret, ExpiresAt: now.Add(store.ttl)}
+	})
+}
+
+/*take - return the secret for a request token and forget it so it can be used only once*/
+func (store *requestTokenStore) take(requestToken string) (string, bool, error) {
+	var login pendingRequestToken
+	var ok bool
+	err := store.update(func(pending map[string]pendingRequestToken) {
+		login, ok = pending[requestToken]
+		delete(pending, requestToken)
+	})
+	if err != nil || !ok || !login.ExpiresAt.After(store.now()) {
+		return "", false, err
+	}
+	return login.Secret, true, nil
+}
+
+/*update - change the pending logins, read and written back under an exclusive lock of the file when there is one*/
+func (store *requestTokenStore) update(change func(pending map[string]pendingRequestToken)) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.path == "" {
+		change(store.pending)
+		return nil
+	}
+	return filelock.Locked(store.path, true, func() error {
+		pending, err := store.load()
+		if err != nil {
+			return err
+		}
+		change(pending)
+		return store.save(pending)
+	})
+}
+
+/*load - read the pending logins, a missing file has none*/
+func (store *requestTokenStore) load() (map[string]pendingRequestToken, error) {
+	pending := make(map[string]pendingRequestToken)
+
+	contents, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return pending, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading request tokens")
+	}
+	if len(contents) == 0 {
+		return pending, nil
+	}
+
+	if err := json.Unmarshal(contents, &pending); err != nil {
+		return nil, errors.Wrap(err, "error parsing request tokens")
+	}
+	return pending, nil
+}
+
+/*save - write the pending logins through a temp file of this write alone so readers never see a partial file*/
+func (store *requestTokenStore) save(pending map[string]pendingRequestToken) error {
+	contents, err := json.Marshal(pending)
+	if err != nil {
+		return errors.Wrap(err, "error encoding request tokens")
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "error writing request tokens")
+	}
+	_, err = tempFile.Write(contents)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), store.path)
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return errors.Wrap(err, "error writing request tokens")
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"splitwiseAngularAPI/splitwisefake"
+
+	"github.com/gorilla/mux"
+)
+
+/*testAPI - the login routes and a route echoing the session user, configured against a fake splitwise*/
+type testAPI struct {
+	fake *splitwisefake.Server
+	api  *httptest.Server
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { devNull.Close() })
+	InitLogger(devNull)
+
+	fake := splitwisefake.NewServer()
+	t.Cleanup(fake.Close)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/", Handle(IndexHandler))
+	router.HandleFunc("/expenses", Handle(CompleteAuth))
+	router.Handle("/whoami", Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, requestSession(r).user)
+	})))
+	api := httptest.NewServer(router)
+	t.Cleanup(api.Close)
+
+	err = Configure(&Configuration{
+		RequestTokenURL:  fake.URL + splitwisefake.RequestTokenPath,
+		AuthorizeURL:     fake.URL + splitwisefake.AuthorizePath,
+		AccessTokenURL:   fake.URL + splitwisefake.AccessTokenPath,
+		ConsumerKey:      "key",
+		ConsumerSecret:   "secret",
+		CallbackURL:      api.URL + "/expenses",
+		AngularHandler:   "http://angular.invalid/",
+		SplitwiseBaseURL: fake.APIURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestTokens = newRequestTokenStore(requestTokenTTL)
+	return &testAPI{fake: fake, api: api}
+}
+
+/*loginFlow - one browser going through IndexHandler, the splitwise authorize page and CompleteAuth step by step. Steps return errors instead of failing the test so flows can run in goroutines*/
+type loginFlow struct {
+	client   *http.Client
+	location string
+}
+
+func (testAPI *testAPI) newLoginFlow() *loginFlow {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	return &loginFlow{client: client, location: testAPI.api.URL + "/"}
+}
+
+/*step - request the current location, return the status and remember where it redirects to*/
+func (flow *loginFlow) step() (int, error) {
+	response, err := flow.client.Get(flow.location)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	if location := response.Header.Get("Location"); location != "" {
+		flow.location = location
+	}
+	return response.StatusCode, nil
+}
+
+/*start - IndexHandler redirects to the authorize page*/
+func (flow *loginFlow) start() error {
+	status, err := flow.step()
+	if err == nil && status != http.StatusFound {
+		err = fmt.Errorf("IndexHandler answered %d, want a redirect to splitwise", status)
+	}
+	return err
+}
+
+/*authorize - approve as userID, splitwise redirects to the callback*/
+func (flow *loginFlow) authorize(userID int) error {
+	authorizeURL, err := url.Parse(flow.location)
+	if err != nil {
+		return err
+	}
+	query := authorizeURL.Query()
+	query.Set("user_id", strconv.Itoa(userID))
+	authorizeURL.RawQuery = query.Encode()
+	flow.location = authorizeURL.String()
+
+	status, err := flow.step()
+	if err == nil && status != http.StatusFound {
+		err = fmt.Errorf("authorize answered %d, want a redirect to the callback", status)
+	}
+	return err
+}
+
+/*callback - CompleteAuth, returns its status*/
+func (flow *loginFlow) callback() (int, error) {
+	return flow.step()
+}
+
+/*whoami - user of the session cookie, "" without a session*/
+func (flow *loginFlow) whoami(testAPI *testAPI) (string, error) {
+	response, err := flow.client.Get(testAPI.api.URL + "/whoami")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", nil
+	}
+	var user string
+	fmt.Fscan(response.Body, &user)
+	return user, nil
+}
+
+/*login - whole flow as userID, returns the user of the resulting session*/
+func (flow *loginFlow) login(testAPI *testAPI, userID int) (string, error) {
+	if err := flow.start(); err != nil {
+		return "", err
+	}
+	if err := flow.authorize(userID); err != nil {
+		return "", err
+	}
+	status, err := flow.callback()
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusFound {
+		return "", fmt.Errorf("callback of user %d answered %d", userID, status)
+	}
+	return flow.whoami(testAPI)
+}
+
+/*must - fail the test on err from a step of a flow*/
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterleavedLogins(t *testing.T) {
+	testAPI := newTestAPI(t)
+	alice := testAPI.newLoginFlow()
+	bob := testAPI.newLoginFlow()
+
+	//both logins are pending at the same time and finish in the opposite order
+	must(t, alice.start())
+	must(t, bob.start())
+	must(t, bob.authorize(splitwisefake.BobID))
+	must(t, alice.authorize(splitwisefake.AliceID))
+	if status, err := bob.callback(); err != nil || status != http.StatusFound {
+		t.Fatalf("bob's callback answered %d, %v", status, err)
+	}
+	if status, err := alice.callback(); err != nil || status != http.StatusFound {
+		t.Fatalf("alice's callback answered %d, %v", status, err)
+	}
+
+	if user, err := alice.whoami(testAPI); err != nil || user != strconv.Itoa(splitwisefake.AliceID) {
+		t.Errorf("alice's cookie belongs to user %q, %v", user, err)
+	}
+	if user, err := bob.whoami(testAPI); err != nil || user != strconv.Itoa(splitwisefake.BobID) {
+		t.Errorf("bob's cookie belongs to user %q, %v", user, err)
+	}
+}
+
+func TestConcurrentLogins(t *testing.T) {
+	testAPI := newTestAPI(t)
+	users := []int{splitwisefake.AliceID, splitwisefake.BobID, splitwisefake.CarolID}
+
+	var wait sync.WaitGroup
+	results := make([]string, len(users))
+	for index, userID := range users {
+		wait.Add(1)
+		go func(index int, userID int) {
+			defer wait.Done()
+			user, err := testAPI.newLoginFlow().login(testAPI, userID)
+			if err != nil {
+				t.Errorf("login of user %d: %v", userID, err)
+				return
+			}
+			results[index] = user
+		}(index, userID)
+	}
+	wait.Wait()
+
+	for index, userID := range users {
+		if results[index] != strconv.Itoa(userID) {
+			t.Errorf("login of user %d ended as user %q", userID, results[index])
+		}
+	}
+}
+
+func TestExpiredRequestToken(t *testing.T) {
+	testAPI := newTestAPI(t)
+	now := time.Now()
+	requestTokens.now = func() time.Time { return now }
+
+	flow := testAPI.newLoginFlow()
+	must(t, flow.start())
+	must(t, flow.authorize(splitwisefake.AliceID))
+
+	now = now.Add(requestTokenTTL + time.Second)
+	if status, err := flow.callback(); err != nil || status != http.StatusUnauthorized {
+		t.Fatalf("callback after the ttl answered %d, %v, want 401", status, err)
+	}
+	if user, err := flow.whoami(testAPI); err != nil || user != "" {
+		t.Errorf("expired login created a session for user %q, %v", user, err)
+	}
+}
+
+func TestReusedRequestToken(t *testing.T) {
+	testAPI := newTestAPI(t)
+
+	flow := testAPI.newLoginFlow()
+	must(t, flow.start())
+	must(t, flow.authorize(splitwisefake.AliceID))
+	callback := flow.location
+	if status, err := flow.callback(); err != nil || status != http.StatusFound {
+		t.Fatalf("first callback answered %d, %v", status, err)
+	}
+
+	//a replayed callback, e.g. from browser history, must not log anyone in
+	replay := testAPI.newLoginFlow()
+	replay.location = callback
+	if status, err := replay.callback(); err != nil || status != http.StatusUnauthorized {
+		t.Fatalf("replayed callback answered %d, %v, want 401", status, err)
+	}
+	if user, err := replay.whoami(testAPI); err != nil || user != "" {
+		t.Errorf("replayed callback created a session for user %q, %v", user, err)
+	}
+}
+
+func TestRequestTokenStoreDropsAbandonedLogins(t *testing.T) {
+	now := time.Now()
+	store := newRequestTokenStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	store.put("abandoned", "secret")
+	now = now.Add(2 * time.Minute)
+	store.put("current", "secret")
+
+	if _, ok := store.pending["abandoned"]; ok {
+		t.Error("abandoned login was kept")
+	}
+	if secret, ok, err := store.take("current"); err != nil || !ok || secret != "secret" {
+		t.Errorf("take(current) = %q, %v, %v", secret, ok, err)
+	}
+}
+
+func TestFileRequestTokensSharedByReplicas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json.logins")
+	first, err := newFileRequestTokenStore(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newFileRequestTokenStore(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the login starts on one replica and its callback reaches the other
+	if err := first.put("token", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if secret, ok, err := second.take("token"); err != nil || !ok || secret != "secret" {
+		t.Errorf("take on the other replica = %q, %v, %v", secret, ok, err)
+	}
+	if _, ok, err := first.take("token"); err != nil || ok {
+		t.Errorf("request token used twice: %v, %v", ok, err)
+	}
+}
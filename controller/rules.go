@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/rules"
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/gorilla/mux"
+)
+
+//categoryRules - categorization rules of every user
+var categoryRules = rules.NewMemoryStore()
+
+/*ruleMatch - an expense a rule matched when testing rules*/
+type ruleMatch struct {
+	ExpenseID           int           `json:"expense_id"`
+	Description         string        `json:"description"`
+	Cost                expense.Money `json:"cost"`
+	CategoryID          int           `json:"category_id"`
+	RuleID              int           `json:"rule_id"`
+	SuggestedCategoryID int           `json:"suggested_category_id"`
+	Agrees              bool          `json:"agrees"`
+}
+
+/*ruleTest - response of TestCategoryRules*/
+type ruleTest struct {
+	GroupID   int         `json:"group_id"`
+	StartDate time.Time   `json:"start_date"`
+	EndDate   time.Time   `json:"end_date"`
+	Tested    int         `json:"tested"`
+	Matched   int         `json:"matched"`
+	Agreed    int         `json:"agreed"`
+	Matches   []ruleMatch `json:"matches"`
+}
+
+/*applyCategoryRules - set the category of request from the user's rules when none was chosen*/
+func applyCategoryRules(user string, request *expense.CreateExpenseRequest) (rules.Rule, bool) {
+	if request.CategoryID != 0 {
+		return rules.Rule{}, false
+	}
+	rule, ok := rules.Suggest(categoryRules.List(user), rules.SubjectOfRequest(request))
+	if ok {
+		request.CategoryID = rule.CategoryID
+	}
+	return rule, ok
+}
+
+/*GetCategoryRules - rules of the current user in priority order*/
+func GetCategoryRules(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	contentJSON, err := json.Marshal(map[string][]rules.Rule{"rules": categoryRules.List(sessionVals.user)})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
+
+/*CreateCategoryRule - add a rule with the lowest priority*/
+func CreateCategoryRule(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	rule, ok := ruleFromRequest(w, r, requestClient(r))
+	if !ok {
+		return
+	}
+
+	rule, err := categoryRules.Add(sessionVals.user, rule)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error saving rule")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+/*DeleteCategoryRule - remove rule {id} of the current user*/
+func DeleteCategoryRule(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	ruleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	deleted, err := categoryRules.Delete(sessionVals.user, ruleID)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusInternalServerError, "error deleting rule")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "rule not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+/*TestCategoryRules - run the stored rules, or the rule posted in the body, against the group's expenses*/
+func TestCategoryRules(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	//get session values
+	sessionVals := requestSession(r)
+
+	q := r.URL.Query()
+	groupID, err := strconv.Atoi(q.Get("groupID"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
+		return
+	}
+	startDate, endDate, err := getStartAndEndDate(q, sessionVals)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client := requestClient(r)
+	ruleList := categoryRules.List(sessionVals.user)
+	if r.Method == "POST" {
+		rule, ok := ruleFromRequest(w, r, client)
+		if !ok {
+			return
+		}
+		ruleList = []rules.Rule{rule}
+	}
+
+	expenses, err := mirroredExpenses(w, r, splitwise.ExpensesQuery{
+		GroupID:     groupID,
+		DatedAfter:  startDate,
+		DatedBefore: endDate,
+	})
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	test := ruleTest{GroupID: groupID, StartDate: startDate, EndDate: endDate, Matches: make([]ruleMatch, 0)}
+	for _, individualExpense := range expenses {
+		if !individualExpense.DeletedAt.IsZero() || individualExpense.Payment {
+			continue
+		}
+		subject, err := rules.SubjectOfExpense(individualExpense)
+		if err != nil {
+			Trace.Println(err)
+			writeJSONError(w, http.StatusBadGateway, "splitwise returned an invalid amount")
+			return
+		}
+		test.Tested++
+
+		rule, ok := rules.Suggest(ruleList, subject)
+		if !ok {
+			continue
+		}
+		match := ruleMatch{
+			ExpenseID:           individualExpense.ID,
+			Description:         individualExpense.Description,
+			Cost:                subject.Cost,
+			CategoryID:          individualExpense.Category.ID,
+			RuleID:              rule.ID,
+			SuggestedCategoryID: rule.CategoryID,
+			Agrees:              rule.CategoryID == individualExpense.Category.ID,
+		}
+		test.Matched++
+		if match.Agrees {
+			test.Agreed++
+		}
+		test.Matches = append(test.Matches, match)
+	}
+
+	//send results
+	contentJSON, err := json.Marshal(test)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Write(contentJSON)
+}
+
+/*ruleFromRequest - decode and validate a rule from the body, writes the error response when it is invalid*/
+func ruleFromRequest(w http.ResponseWriter, r *http.Request, client *splitwise.Client) (rules.Rule, bool) {
+	var rule rules.Rule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	defer r.Body.Close()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid rule: "+err.Error())
+		return rules.Rule{}, false
+	}
+	if err := rule.Validate(); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return rules.Rule{}, false
+	}
+
+	categories, err := client.GetCategories()
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return rules.Rule{}, false
+	}
+	if !expense.CategoryExists(categories, rule.CategoryID) {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, map[string][]string{"category_id": {"unknown category"}})
+		return rules.Rule{}, false
+	}
+	return rule, true
+}
@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"splitwiseAngularAPI/filelock"
+
+	"github.com/dghubble/oauth1"
+	"github.com/pkg/errors"
+)
+
+//sessionMaxAge - lifetime of a session and its cookie in seconds
+const sessionMaxAge = 60 * 60
+
+/*SessionStore - storage for logged in user sessions keyed by splitwise user id*/
+type SessionStore interface {
+	//Get returns the session for user or nil if there is none or it has expired
+	Get(user string) (*sessionValues, error)
+	//Put saves or replaces the session for user
+	Put(user string, session *sessionValues) error
+	//Delete removes the session for user
+	Delete(user string) error
+	//Expire removes all sessions which have expired
+	Expire() error
+}
+
+/*newSessionStore - create the session store selected in configuration*/
+func newSessionStore(conf *Configuration) (SessionStore, error) {
+	switch conf.SessionStore {
+	case "", "memory":
+		return newMemorySessionStore(), nil
+	case "file":
+		if conf.SessionStorePath == "" {
+			return nil, errors.New("SessionStorePath is required for file session store")
+		}
+		return newFileSessionStore(conf.SessionStorePath)
+	default:
+		return nil, errors.Errorf("unknown session store %q", conf.SessionStore)
+	}
+}
+
+/********************************************memory store*******************************/
+
+/*memorySessionStore - in process session store, sessions are lost on restart*/
+type memorySessionStore struct {
+	mutex    sync.Mutex
+	sessions map[string]*sessionValues
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*sessionValues)}
+}
+
+func (store *memorySessionStore) Get(user string) (*sessionValues, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	session := store.sessions[user]
+	if session == nil || session.expired(time.Now()) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (store *memorySessionStore) Put(user string, session *sessionValues) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.sessions[user] = session
+	return nil
+}
+
+func (store *memorySessionStore) Delete(user string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.sessions, user)
+	return nil
+}
+
+func (store *memorySessionStore) Expire() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	for user, session := range store.sessions {
+		if session.expired(now) {
+			delete(store.sessions, user)
+		}
+	}
+	return nil
+}
+
+/********************************************file store*******************************/
+
+/*storedSession - on disk representation of sessionValues*/
+type storedSession struct {
+	SessionID   string    `json:"session_id"`
+	TimeZone    string    `json:"time_zone,omitempty"`
+	Token       string    `json:"token"`
+	TokenSecret string    `json:"token_secret"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+/*fileSessionStore - session store persisted as json to a file so sessions survive restarts and can be shared by replicas mounting the same volume. The mutex orders goroutines of this process, an os lock on path+".lock" orders replicas*/
+type fileSessionStore struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func newFileSessionStore(path string) (*fileSessionStore, error) {
+	store := &fileSessionStore{path: path}
+
+	//make sure the file is readable before accepting logins
+	err := store.locked(false, func() error {
+		_, err := store.load()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *fileSessionStore) Get(user string) (*sessionValues, error) {
+	var sessions map[string]storedSession
+	err := store.locked(false, func() (err error) {
+		sessions, err = store.load()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stored, ok := sessions[user]
+	if !ok {
+		return nil, nil
+	}
+	session := &sessionValues{
+		user:      user,
+		timeZone:  stored.TimeZone,
+		sessionID: stored.SessionID,
+		token:     oauth1.NewToken(stored.Token, stored.TokenSecret),
+		expiresAt: stored.ExpiresAt,
+	}
+	if session.expired(time.Now()) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (store *fileSessionStore) Put(user string, session *sessionValues) error {
+	return store.update(func(sessions map[string]storedSession) {
+		sessions[user] = storedSession{
+			SessionID:   session.sessionID,
+			TimeZone:    session.timeZone,
+			Token:       session.token.Token,
+			TokenSecret: session.token.TokenSecret,
+			ExpiresAt:   session.expiresAt,
+		}
+	})
+}
+
+func (store *fileSessionStore) Delete(user string) error {
+	return store.update(func(sessions map[string]storedSession) {
+		delete(sessions, user)
+	})
+}
+
+func (store *fileSessionStore) Expire() error {
+	now := time.Now()
+	return store.update(func(sessions map[string]storedSession) {
+		for user, stored := range sessions {
+			if !stored.ExpiresAt.After(now) {
+				delete(sessions, user)
+			}
+		}
+	})
+}
+
+/*update - read, change and write the sessions while holding the lock, so no replica's change is lost in between*/
+func (store *fileSessionStore) update(change func(sessions map[string]storedSession)) error {
+	return store.locked(true, func() error {
+		sessions, err := store.load()
+		if err != nil {
+			return err
+		}
+		change(sessions)
+		return store.save(sessions)
+	})
+}
+
+/*locked - run fn holding the mutex and the os lock on the lock file, exclusive for writers and shared for readers*/
+func (store *fileSessionStore) locked(exclusive bool, fn func() error) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return filelock.Locked(store.path, exclusive, fn)
+}
+
+/*load - read all sessions, a missing file is an empty store*/
+func (store *fileSessionStore) load() (map[string]storedSession, error) {
+	sessions := make(map[string]storedSession)
+
+	contents, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading session store")
+	}
+	if len(contents) == 0 {
+		return sessions, nil
+	}
+
+	if err := json.Unmarshal(contents, &sessions); err != nil {
+		return nil, errors.Wrap(err, "error parsing session store")
+	}
+	return sessions, nil
+}
+
+/*save - write all sessions through a temp file of this write alone so readers never see a partial file*/
+func (store *fileSessionStore) save(sessions map[string]storedSession) error {
+	contents, err := json.Marshal(sessions)
+	if err != nil {
+		return errors.Wrap(err, "error encoding session store")
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "error writing session store")
+	}
+	_, err = tempFile.Write(contents)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), store.path)
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return errors.Wrap(err, "error writing session store")
+	}
+	return nil
+}
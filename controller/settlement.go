@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/settle"
+	"splitwiseAngularAPI/splitwise"
+)
+
+/*GetSettlementPlan - transfers settling only the expenses of a group within a date range, e.g. a single trip or month, computed locally per currency*/
+func GetSettlementPlan(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	defer r.Body.Close()
+	q := r.URL.Query()
+	groupID, err := strconv.Atoi(q.Get("groupID"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
+		return
+	}
+	startDate, endDate, err := getStartAndEndDate(q, sessionVals)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	expenses, err := mirroredExpenses(w, r, splitwise.ExpensesQuery{
+		GroupID:     groupID,
+		DatedAfter:  startDate,
+		DatedBefore: endDate,
+	})
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	transfers, err := settle.Plan(settle.FilterByDate(expenses, startDate, endDate))
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusBadGateway, "splitwise returned an invalid amount")
+		return
+	}
+
+	//send response
+	contentJSON, err := json.Marshal(expense.SettlementPlan{
+		GroupID:   groupID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Transfers: transfers,
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
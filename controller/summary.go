@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/splitwise"
+	"splitwiseAngularAPI/summary"
+)
+
+/*groupSummary - response of GetGroupSummary*/
+type groupSummary struct {
+	GroupID   int             `json:"group_id"`
+	StartDate time.Time       `json:"start_date"`
+	EndDate   time.Time       `json:"end_date"`
+	Summary   summary.Summary `json:"summary"`
+}
+
+/*GetGroupSummary - expense totals grouped by ?groupBy=category,member,month*/
+func GetGroupSummary(w http.ResponseWriter, r *http.Request) {
+	//get session values
+	sessionVals := requestSession(r)
+
+	defer r.Body.Close()
+	q := r.URL.Query()
+	groupID, err := strconv.Atoi(q.Get("groupID"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "groupID is required")
+		return
+	}
+
+	dimensions := summary.Dimensions
+	if groupBy := q.Get("groupBy"); groupBy != "" {
+		dimensions = strings.Split(groupBy, ",")
+		for _, dimension := range dimensions {
+			if !summary.ValidDimension(dimension) {
+				writeJSONError(w, http.StatusBadRequest, "groupBy must be a list of "+strings.Join(summary.Dimensions, ", "))
+				return
+			}
+		}
+	}
+	startDate, endDate, err := getStartAndEndDate(q, sessionVals)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reportCurrency, err := reportCurrencyFromQuery(q)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client := requestClient(r)
+	group, err := client.GetGroup(groupID)
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+	expenses, err := mirroredExpenses(w, r, splitwise.ExpensesQuery{
+		GroupID:     groupID,
+		DatedAfter:  startDate,
+		DatedBefore: endDate,
+	})
+	if err != nil {
+		writeSplitwiseError(w, err)
+		return
+	}
+
+	memberNames := make(map[int]string)
+	for _, member := range group.Members {
+		memberNames[member.ID] = member.FirstName
+	}
+
+	expenses, err = convertExpenses(expenses, reportCurrency)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	expenses = expensesInLocation(expenses, startDate.Location())
+	expenseSummary, err := summary.Summarize(expenses, dimensions, memberNames)
+	if err != nil {
+		Trace.Println(err)
+		writeJSONError(w, http.StatusBadGateway, "splitwise returned an invalid amount")
+		return
+	}
+
+	//send response
+	contentJSON, err := json.Marshal(groupSummary{
+		GroupID:   groupID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Summary:   expenseSummary,
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", config.AngularHandler)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Write(contentJSON)
+}
@@ -0,0 +1,181 @@
+package currency
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+/*RateTable - dated exchange rates against a single base currency*/
+type RateTable struct {
+	Base  string
+	rates map[string][]datedRate
+}
+
+type datedRate struct {
+	date time.Time
+	rate *big.Rat
+}
+
+/*rateFile - on disk format, rate is how many units of base one unit of currency_code buys: {"base": "USD", "rates": [{"date": "2019-05-01", "currency_code": "EUR", "rate": "1.12"}]}*/
+type rateFile struct {
+	Base  string `json:"base"`
+	Rates []struct {
+		Date         string `json:"date"`
+		CurrencyCode string `json:"currency_code"`
+		Rate         string `json:"rate"`
+	} `json:"rates"`
+}
+
+/*LoadRateTable - read a rate table file*/
+func LoadRateTable(path string) (*RateTable, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading exchange rates")
+	}
+	return ParseRateTable(contents)
+}
+
+/*ParseRateTable - parse a rate table in the LoadRateTable file format*/
+func ParseRateTable(contents []byte) (*RateTable, error) {
+	var file rateFile
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return nil, errors.Wrap(err, "error parsing exchange rates")
+	}
+	if file.Base == "" {
+		return nil, errors.New("exchange rates need a base currency")
+	}
+
+	table := &RateTable{Base: strings.ToUpper(file.Base), rates: make(map[string][]datedRate)}
+	for index, entry := range file.Rates {
+		date, err := time.Parse("2006-01-02", entry.Date)
+		if err != nil {
+			return nil, errors.Errorf("exchange rate %d: invalid date %q", index, entry.Date)
+		}
+		rate, ok := new(big.Rat).SetString(entry.Rate)
+		if !ok || rate.Sign() <= 0 {
+			return nil, errors.Errorf("exchange rate %d: invalid rate %q", index, entry.Rate)
+		}
+		currency := strings.ToUpper(entry.CurrencyCode)
+		table.rates[currency] = append(table.rates[currency], datedRate{date: date, rate: rate})
+	}
+
+	for _, rates := range table.rates {
+		sort.Slice(rates, func(i, j int) bool { return rates[i].date.Before(rates[j].date) })
+	}
+	return table, nil
+}
+
+/*baseRate - units of base for one unit of currency on date, using the latest rate dated on or before date*/
+func (table *RateTable) baseRate(currency string, date time.Time) (*big.Rat, error) {
+	currency = strings.ToUpper(currency)
+	if currency == table.Base {
+		return big.NewRat(1, 1), nil
+	}
+
+	rates := table.rates[currency]
+	index := sort.Search(len(rates), func(i int) bool { return rates[i].date.After(date) })
+	if index == 0 {
+		return nil, errors.Errorf("no %s exchange rate on or before %s", currency, date.Format("2006-01-02"))
+	}
+	return rates[index-1].rate, nil
+}
+
+/*Rate - units of to for one unit of from on date*/
+func (table *RateTable) Rate(from string, to string, date time.Time) (*big.Rat, error) {
+	fromRate, err := table.baseRate(from, date)
+	if err != nil {
+		return nil, err
+	}
+	toRate, err := table.baseRate(to, date)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Quo(fromRate, toRate), nil
+}
+
+/*Convert - money in currency to on date, rounded half away from zero to to's minor unit. The rate used is returned with it*/
+func (table *RateTable) Convert(money expense.Money, to string, date time.Time) (expense.Money, *big.Rat, error) {
+	rate, err := table.Rate(money.Currency, to, date)
+	if err != nil {
+		return expense.Money{}, nil, err
+	}
+	return convert(money, to, rate), rate, nil
+}
+
+func convert(money expense.Money, to string, rate *big.Rat) expense.Money {
+	//minor units of from -> major units of from -> major units of to -> minor units of to
+	scale := func(digits int) *big.Int {
+		return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	}
+	amount := new(big.Rat).SetFrac(big.NewInt(money.Minor), scale(expense.MinorUnitDigits(money.Currency)))
+	amount.Mul(amount, rate)
+	amount.Mul(amount, new(big.Rat).SetInt(scale(expense.MinorUnitDigits(to))))
+
+	return expense.Money{Minor: roundRat(amount), Currency: to}
+}
+
+/*roundRat - nearest integer, halves away from zero*/
+func roundRat(value *big.Rat) int64 {
+	numerator := new(big.Int).Abs(value.Num())
+	denominator := value.Denom()
+
+	//(2n + d) / 2d
+	doubled := new(big.Int).Mul(numerator, big.NewInt(2))
+	doubled.Add(doubled, denominator)
+	rounded := new(big.Int).Quo(doubled, new(big.Int).Mul(denominator, big.NewInt(2)))
+	if value.Sign() < 0 {
+		rounded.Neg(rounded)
+	}
+	return rounded.Int64()
+}
+
+/*ConvertExpense - copy of an expense with all shares converted to currency to at the expense date, and the rate used*/
+func (table *RateTable) ConvertExpense(individualExpense expense.Expense, to string) (expense.Expense, *big.Rat, error) {
+	rate, err := table.Rate(individualExpense.CurrencyCode, to, individualExpense.Date)
+	if err != nil {
+		return expense.Expense{}, nil, err
+	}
+
+	converted := individualExpense
+	converted.CurrencyCode = to
+	if individualExpense.Cost != "" {
+		cost, err := expense.ParseMoney(individualExpense.Cost, individualExpense.CurrencyCode)
+		if err != nil {
+			return expense.Expense{}, nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+		}
+		converted.Cost = convert(cost, to, rate).String()
+	}
+	converted.Users = make([]expense.UserInfo, len(individualExpense.Users))
+	for index, userInfo := range individualExpense.Users {
+		paid, err := userInfo.Paid(individualExpense.CurrencyCode)
+		if err != nil {
+			return expense.Expense{}, nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+		}
+		owed, err := userInfo.Owed(individualExpense.CurrencyCode)
+		if err != nil {
+			return expense.Expense{}, nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+		}
+		net, err := userInfo.Net(individualExpense.CurrencyCode)
+		if err != nil {
+			return expense.Expense{}, nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+		}
+		userInfo.PaidShare = convert(paid, to, rate).String()
+		userInfo.OwedShare = convert(owed, to, rate).String()
+		userInfo.NetBalance = convert(net, to, rate).String()
+		converted.Users[index] = userInfo
+	}
+	return converted, rate, nil
+}
+
+/*FormatRate - rate as a decimal string for responses*/
+func FormatRate(rate *big.Rat) string {
+	return rate.FloatString(6)
+}
@@ -0,0 +1,185 @@
+package daterange
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//legacyParams - the original six date parameters, all of them are required together
+var legacyParams = []string{"startYear", "startMonth", "startDay", "endYear", "endMonth", "endDay"}
+
+var lastDaysPattern = regexp.MustCompile(`^last(\d+)d$`)
+
+//Ranges - relative ranges accepted by ?range=, lastNd works for any number of days
+var Ranges = []string{"lastNd", "thisMonth", "lastMonth", "thisQuarter", "lastQuarter", "ytd", "lastYear"}
+
+/*FromQuery - [start, end) from ?from=&to=, ?range= or the legacy startYear..endDay parameters, in the ?tz= time zone or defaultLocation*/
+func FromQuery(query url.Values, now time.Time, defaultLocation *time.Location) (time.Time, time.Time, error) {
+	location, err := Location(query, defaultLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	now = now.In(location)
+
+	styles := 0
+	if query.Get("from") != "" || query.Get("to") != "" {
+		styles++
+	}
+	if query.Get("range") != "" {
+		styles++
+	}
+	for _, param := range legacyParams {
+		if _, ok := query[param]; ok {
+			styles++
+			break
+		}
+	}
+	if styles == 0 {
+		return time.Time{}, time.Time{}, errors.New("a date range is required: from and to, range, or startYear, startMonth, startDay, endYear, endMonth and endDay")
+	}
+	if styles > 1 {
+		return time.Time{}, time.Time{}, errors.New("use only one of from and to, range, or the startYear..endDay parameters")
+	}
+
+	var start, end time.Time
+	switch {
+	case query.Get("range") != "":
+		start, end, err = Relative(query.Get("range"), now)
+	case query.Get("from") != "" || query.Get("to") != "":
+		start, end, err = fromTo(query.Get("from"), query.Get("to"), now, location)
+	default:
+		start, end, err = legacy(query, location)
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("the end of the date range must be after its start")
+	}
+	return start, end, nil
+}
+
+/*Location - the ?tz= time zone, defaultLocation when it is not set*/
+func Location(query url.Values, defaultLocation *time.Location) (*time.Location, error) {
+	name := query.Get("tz")
+	if name == "" {
+		return defaultLocation, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.Errorf("unknown time zone %q", name)
+	}
+	return location, nil
+}
+
+/*Relative - [start, end) of a named range around now, in now's location*/
+func Relative(name string, now time.Time) (time.Time, time.Time, error) {
+	today := midnight(now.Year(), now.Month(), now.Day(), now.Location())
+	tomorrow := today.AddDate(0, 0, 1)
+	quarterMonth := time.Month((int(now.Month())-1)/3*3 + 1)
+	thisQuarter := midnight(now.Year(), quarterMonth, 1, now.Location())
+	thisMonth := midnight(now.Year(), now.Month(), 1, now.Location())
+	thisYear := midnight(now.Year(), time.January, 1, now.Location())
+
+	if match := lastDaysPattern.FindStringSubmatch(name); match != nil {
+		days, err := strconv.Atoi(match[1])
+		if err != nil || days < 1 || days > 36600 {
+			return time.Time{}, time.Time{}, errors.Errorf("invalid range %q", name)
+		}
+		return today.AddDate(0, 0, 1-days), tomorrow, nil
+	}
+
+	switch name {
+	case "thisMonth":
+		return thisMonth, thisMonth.AddDate(0, 1, 0), nil
+	case "lastMonth":
+		return thisMonth.AddDate(0, -1, 0), thisMonth, nil
+	case "thisQuarter":
+		return thisQuarter, thisQuarter.AddDate(0, 3, 0), nil
+	case "lastQuarter":
+		return thisQuarter.AddDate(0, -3, 0), thisQuarter, nil
+	case "ytd":
+		return thisYear, tomorrow, nil
+	case "lastYear":
+		return thisYear.AddDate(-1, 0, 0), thisYear, nil
+	}
+	return time.Time{}, time.Time{}, errors.Errorf("unknown range %q, expected one of %s", name, strings.Join(Ranges, ", "))
+}
+
+/*fromTo - from and to as 2006-01-02 dates, to inclusive, or RFC3339 times, to exclusive. to defaults to the end of today*/
+func fromTo(from string, to string, now time.Time, location *time.Location) (time.Time, time.Time, error) {
+	if from == "" {
+		return time.Time{}, time.Time{}, errors.New("from is required with to")
+	}
+	start, _, err := parseBound("from", from, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to == "" {
+		return start, midnight(now.Year(), now.Month(), now.Day()+1, location), nil
+	}
+	end, isDate, err := parseBound("to", to, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if isDate {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
+
+/*parseBound - ISO-8601 date or time, isDate when it had no time of day*/
+func parseBound(name string, value string, location *time.Location) (time.Time, bool, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, location); err == nil {
+		return date, true, nil
+	}
+	if instant, err := time.Parse(time.RFC3339, value); err == nil {
+		return instant.In(location), false, nil
+	}
+	return time.Time{}, false, errors.Errorf("%s must be an ISO-8601 date like 2019-05-01 or time like 2019-05-01T10:00:00Z, got %q", name, value)
+}
+
+/*legacy - startYear, startMonth, startDay to endYear, endMonth, endDay inclusive*/
+func legacy(query url.Values, location *time.Location) (time.Time, time.Time, error) {
+	values := make([]int, len(legacyParams))
+	for index, param := range legacyParams {
+		value := query.Get(param)
+		if value == "" {
+			return time.Time{}, time.Time{}, errors.Errorf("%s is required", param)
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.Errorf("%s must be a number, got %q", param, value)
+		}
+		values[index] = number
+	}
+
+	start, err := legacyDate("start", values[0], values[1], values[2], location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := legacyDate("end", values[3], values[4], values[5], location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	//before end of the end day
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+/*legacyDate - date which must exist, time.Date would silently roll 2019-02-30 over into March*/
+func legacyDate(name string, year int, month int, day int, location *time.Location) (time.Time, error) {
+	date := midnight(year, time.Month(month), day, location)
+	if year < 1 || date.Year() != year || date.Month() != time.Month(month) || date.Day() != day {
+		return time.Time{}, errors.Errorf("%s date %04d-%02d-%02d does not exist", name, year, month, day)
+	}
+	return date, nil
+}
+
+func midnight(year int, month time.Month, day int, location *time.Location) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
@@ -0,0 +1,91 @@
+package daterange
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFromQuery(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	//a wednesday in january
+	now := time.Date(2019, 1, 16, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query string
+		start string
+		end   string
+		valid bool
+	}{
+		{"from=2019-05-01&to=2019-05-31", "2019-05-01T00:00:00Z", "2019-06-01T00:00:00Z", true},
+		{"from=2019-05-01T10:00:00Z&to=2019-05-01T12:00:00Z", "2019-05-01T10:00:00Z", "2019-05-01T12:00:00Z", true},
+		{"from=2019-01-01", "2019-01-01T00:00:00Z", "2019-01-17T00:00:00Z", true},
+		{"to=2019-05-31", "", "", false},
+		{"from=2019-05-31&to=2019-05-01", "", "", false},
+		{"from=2019-05-01T12:00:00Z&to=2019-05-01T10:00:00Z", "", "", false},
+		{"from=2019-05-01T10:00:00Z&to=2019-05-01T10:00:00Z", "", "", false},
+		{"from=2019-02-30&to=2019-03-01", "", "", false},
+		{"from=yesterday", "", "", false},
+
+		{"range=last7d", "2019-01-10T00:00:00Z", "2019-01-17T00:00:00Z", true},
+		{"range=last1d", "2019-01-16T00:00:00Z", "2019-01-17T00:00:00Z", true},
+		{"range=last0d", "", "", false},
+		{"range=last36601d", "", "", false},
+		{"range=thisMonth", "2019-01-01T00:00:00Z", "2019-02-01T00:00:00Z", true},
+		{"range=lastMonth", "2018-12-01T00:00:00Z", "2019-01-01T00:00:00Z", true},
+		{"range=thisQuarter", "2019-01-01T00:00:00Z", "2019-04-01T00:00:00Z", true},
+		//the quarter before the first one is in the previous year
+		{"range=lastQuarter", "2018-10-01T00:00:00Z", "2019-01-01T00:00:00Z", true},
+		{"range=ytd", "2019-01-01T00:00:00Z", "2019-01-17T00:00:00Z", true},
+		{"range=lastYear", "2018-01-01T00:00:00Z", "2019-01-01T00:00:00Z", true},
+		{"range=nextWeek", "", "", false},
+
+		{"startYear=2019&startMonth=5&startDay=1&endYear=2019&endMonth=5&endDay=31", "2019-05-01T00:00:00Z", "2019-06-01T00:00:00Z", true},
+		{"startYear=2019&startMonth=5&startDay=1", "", "", false},
+		{"startYear=2019&startMonth=5&startDay=1&endYear=2019&endMonth=5&endDay=", "", "", false},
+		{"startYear=2019&startMonth=May&startDay=1&endYear=2019&endMonth=5&endDay=31", "", "", false},
+		{"startYear=2019&startMonth=2&startDay=30&endYear=2019&endMonth=3&endDay=31", "", "", false},
+		{"startYear=2019&startMonth=5&startDay=31&endYear=2019&endMonth=5&endDay=1", "", "", false},
+		{"startYear=2019&startMonth=2&startDay=28&endYear=2019&endMonth=2&endDay=28", "2019-02-28T00:00:00Z", "2019-03-01T00:00:00Z", true},
+
+		{"", "", "", false},
+		{"range=last7d&from=2019-05-01", "", "", false},
+		{"from=2019-05-01&to=2019-05-31&startYear=2019", "", "", false},
+		{"range=thisMonth&endDay=31", "", "", false},
+
+		{"range=thisMonth&tz=America/New_York", "2019-01-01T05:00:00Z", "2019-02-01T05:00:00Z", true},
+		//now is the evening of the 16th in tokyo, its days start at 15:00 UTC
+		{"range=last1d&tz=Asia/Tokyo", "2019-01-15T15:00:00Z", "2019-01-16T15:00:00Z", true},
+		{"from=2019-05-01&to=2019-05-01&tz=Asia/Tokyo", "2019-04-30T15:00:00Z", "2019-05-01T15:00:00Z", true},
+		{"range=thisMonth&tz=Mars/Olympus_Mons", "", "", false},
+	}
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, end, err := FromQuery(query, now, time.UTC)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: error %v, want valid %v", test.query, err, test.valid)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if got := start.UTC().Format(time.RFC3339); got != test.start {
+			t.Errorf("%q: start %s, want %s", test.query, got, test.start)
+		}
+		if got := end.UTC().Format(time.RFC3339); got != test.end {
+			t.Errorf("%q: end %s, want %s", test.query, got, test.end)
+		}
+	}
+
+	//without ?tz= the default location is used
+	start, _, err := FromQuery(url.Values{"range": {"thisMonth"}}, now, newYork)
+	if err != nil || start.Location() != newYork || start.Format("2006-01-02 15:04") != "2019-01-01 00:00" {
+		t.Errorf("start %s, %v, want midnight of january 1st in new york", start, err)
+	}
+}
@@ -7,6 +7,11 @@ type ExpensesWrapper struct {
 	Expenses []Expense `json:"expenses"`
 }
 
+/*ExpenseWrapper  Wrapper to a single expense*/
+type ExpenseWrapper struct {
+	Expense Expense `json:"expense"`
+}
+
 /*Category type of expense*/
 type Category struct {
 	ID   int    `json:"id"`
@@ -15,44 +20,137 @@ type Category struct {
 
 /*UserInfo - User information*/
 type UserInfo struct {
-	UserID    int    `json:"user_id"`
-	OwedShare string `json:"owed_share"`
+	UserID     int    `json:"user_id"`
+	PaidShare  string `json:"paid_share"`
+	OwedShare  string `json:"owed_share"`
+	NetBalance string `json:"net_balance"`
+}
+
+/*Paid - paid share in currency*/
+func (userInfo UserInfo) Paid(currency string) (Money, error) {
+	return ParseMoney(userInfo.PaidShare, currency)
+}
+
+/*Owed - owed share in currency*/
+func (userInfo UserInfo) Owed(currency string) (Money, error) {
+	return ParseMoney(userInfo.OwedShare, currency)
+}
+
+/*Net - net balance in currency, paid minus owed when splitwise did not send it*/
+func (userInfo UserInfo) Net(currency string) (Money, error) {
+	if userInfo.NetBalance != "" {
+		return ParseMoney(userInfo.NetBalance, currency)
+	}
+	paid, err := userInfo.Paid(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	owed, err := userInfo.Owed(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return paid.Add(owed.Neg())
 }
 
 /*Expense - a single expense*/
 type Expense struct {
-	ID          int        `json:"id"`
-	GroupID     int        `json:"group_id"`
-	Description string     `json:"description"`
-	Date        time.Time  `json:"date"`
-	Category    Category   `json:"category"`
-	Users       []UserInfo `json:"users"`
-	DeletedAt   time.Time  `json:"deleted_at"`
+	ID           int        `json:"id"`
+	GroupID      int        `json:"group_id"`
+	Description  string     `json:"description"`
+	Cost         string     `json:"cost"`
+	CurrencyCode string     `json:"currency_code"`
+	Payment      bool       `json:"payment"`
+	Date         time.Time  `json:"date"`
+	Category     Category   `json:"category"`
+	Users        []UserInfo `json:"users"`
+	CreatedBy    *User      `json:"created_by"`
+	DeletedAt    time.Time  `json:"deleted_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
 /*ResponseExpense - a single expense with category*/
 type ResponseExpense struct {
+	ExpenseID   int       `json:"expense_id"`
 	Category    string    `json:"category"`
 	UserID      int       `json:"user_id"`
-	OwedShare   string    `json:"owed_share"`
+	OwedShare   Money     `json:"owed_share"`
+	PaidShare   Money     `json:"paid_share"`
+	NetBalance  Money     `json:"net_balance"`
+	Cost        Money     `json:"cost"`
+	Payment     bool      `json:"payment"`
+	CreatedBy   int       `json:"created_by"`
 	Date        time.Time `json:"date"`
 	Description string    `json:"description"`
+	//CurrencyCode - currency of the amounts
+	CurrencyCode string `json:"currency_code"`
+	//ReportOwedShare - OwedShare converted to ReportCurrency at ExchangeRate when requested
+	ReportCurrency  string `json:"report_currency,omitempty"`
+	ReportOwedShare *Money `json:"report_owed_share,omitempty"`
+	ExchangeRate    string `json:"exchange_rate,omitempty"`
 }
 
 /********************************************User Structs*******************************/
+type UserWrapper struct {
+	User User `json:"user"`
+}
+
+type User struct {
+	ID              int    `json:"id"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	Email           string `json:"email"`
+	DefaultCurrency string `json:"default_currency"`
+	//TimeZone - IANA time zone of the user's splitwise profile, e.g. America/New_York
+	TimeZone string `json:"time_zone"`
+}
+
 type GroupWrapper struct {
 	Group Group `json:"group"`
 }
 
 type Members struct {
-	ID        int    `json:"id"`
-	FirstName string `json:"first_name"`
+	ID        int       `json:"id"`
+	FirstName string    `json:"first_name"`
+	Balance   []Balance `json:"balance"`
 }
 
 type Group struct {
-	ID      int       `json:"ID"`
-	Name    string    `json:"Name"`
-	Members []Members `json:"members"`
+	ID                int       `json:"ID"`
+	Name              string    `json:"Name"`
+	Members           []Members `json:"members"`
+	SimplifyByDefault bool      `json:"simplify_by_default"`
+	OriginalDebts     []Debt    `json:"original_debts,omitempty"`
+	SimplifiedDebts   []Debt    `json:"simplified_debts,omitempty"`
+}
+
+/*Balance - net balance of a member in one currency, positive when the member is owed*/
+type Balance struct {
+	CurrencyCode string `json:"currency_code"`
+	Amount       string `json:"amount"`
+}
+
+/*Debt - From owes To Amount*/
+type Debt struct {
+	From         int    `json:"from"`
+	To           int    `json:"to"`
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currency_code"`
+}
+
+/*SettlementPlan - transfers settling all expenses of a group within a date range*/
+type SettlementPlan struct {
+	GroupID   int       `json:"group_id"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Transfers []Debt    `json:"transfers"`
+}
+
+/*GroupBalances - balances of all members and who owes whom*/
+type GroupBalances struct {
+	GroupID    int       `json:"group_id"`
+	Members    []Members `json:"members"`
+	Simplified bool      `json:"simplified"`
+	Debts      []Debt    `json:"debts"`
 }
 
 type GroupArrWrapper struct {
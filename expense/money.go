@@ -0,0 +1,175 @@
+package expense
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*Money - exact amount in minor units of a currency, marshals to json as a decimal string*/
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+//minorUnitDigits - currencies without two decimal minor units
+var minorUnitDigits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+/*MinorUnitDigits - number of decimals of currency, 2 when unknown*/
+func MinorUnitDigits(currency string) int {
+	if digits, ok := minorUnitDigits[strings.ToUpper(currency)]; ok {
+		return digits
+	}
+	return 2
+}
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+/*IsCurrencyCode - whether code looks like an ISO 4217 code, e.g. USD*/
+func IsCurrencyCode(code string) bool {
+	return currencyCodePattern.MatchString(code)
+}
+
+var amountPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+/*ParseMoney - parse a splitwise amount like "12.3", extra decimals must be zero*/
+func ParseMoney(amount string, currency string) (Money, error) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return Money{Currency: currency}, nil
+	}
+	if !amountPattern.MatchString(amount) {
+		return Money{}, fmt.Errorf("%q is not a valid amount", amount)
+	}
+
+	digits := MinorUnitDigits(currency)
+	negative := strings.HasPrefix(amount, "-")
+	parts := strings.SplitN(strings.TrimPrefix(amount, "-"), ".", 2)
+	fraction := ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+	}
+	if len(fraction) > digits {
+		if strings.Trim(fraction[digits:], "0") != "" {
+			return Money{}, fmt.Errorf("%q has more than %d decimals", amount, digits)
+		}
+		fraction = fraction[:digits]
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+
+	minor, err := strconv.ParseInt(parts[0]+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%q is not a valid amount", amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+/*String - decimal amount with the currency's number of decimals, e.g. "12.30"*/
+func (money Money) String() string {
+	minor := money.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	digits := MinorUnitDigits(money.Currency)
+	if digits == 0 {
+		return sign + strconv.FormatInt(minor, 10)
+	}
+	scale := int64(1)
+	for index := 0; index < digits; index++ {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/scale, digits, minor%scale)
+}
+
+/*IsZero - whether the amount is zero*/
+func (money Money) IsZero() bool {
+	return money.Minor == 0
+}
+
+/*Add - sum of two amounts of the same currency, a zero value without currency takes the other's currency*/
+func (money Money) Add(other Money) (Money, error) {
+	currency := money.Currency
+	switch {
+	case currency == "":
+		currency = other.Currency
+	case other.Currency != "" && other.Currency != currency:
+		return Money{}, fmt.Errorf("cannot add %s to %s", other.Currency, currency)
+	}
+	return Money{Minor: money.Minor + other.Minor, Currency: currency}, nil
+}
+
+/*Neg - negated amount*/
+func (money Money) Neg() Money {
+	return Money{Minor: -money.Minor, Currency: money.Currency}
+}
+
+/*Split - divide into parts that add up exactly to money, leftover minor units go one each to the first parts so the result is deterministic*/
+func (money Money) Split(parts int) []Money {
+	if parts <= 0 {
+		return nil
+	}
+
+	quotient := money.Minor / int64(parts)
+	remainder := money.Minor % int64(parts)
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+		remainder = -remainder
+	}
+
+	split := make([]Money, parts)
+	for index := range split {
+		split[index] = Money{Minor: quotient, Currency: money.Currency}
+		if int64(index) < remainder {
+			split[index].Minor += step
+		}
+	}
+	return split
+}
+
+/*Divide - money / count rounded half away from zero, e.g. for averages*/
+func (money Money) Divide(count int) Money {
+	if count <= 0 {
+		return Money{Currency: money.Currency}
+	}
+	minor := money.Minor
+	if minor < 0 {
+		minor = -minor
+	}
+	minor = (minor + int64(count)/2) / int64(count)
+	if money.Minor < 0 {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: money.Currency}
+}
+
+/*MarshalJSON - the decimal amount as a json string*/
+func (money Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(money.String())
+}
+
+/*UnmarshalJSON - accepts a decimal string or number, keeps a currency set beforehand*/
+func (money *Money) UnmarshalJSON(data []byte) error {
+	var amount flexString
+	if err := amount.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	parsed, err := ParseMoney(string(amount), money.Currency)
+	if err != nil {
+		return err
+	}
+	*money = parsed
+	return nil
+}
@@ -0,0 +1,111 @@
+package expense
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		minor    int64
+		valid    bool
+	}{
+		{"12.3", "USD", 1230, true},
+		{"12", "USD", 1200, true},
+		{" 12.30 ", "USD", 1230, true},
+		{"", "USD", 0, true},
+		{"-12.30", "USD", -1230, true},
+		{"-0.05", "USD", -5, true},
+		{"-0", "USD", 0, true},
+		//trailing zeros beyond the currency's decimals are only formatting
+		{"12.3000", "USD", 1230, true},
+		{"1000.0", "JPY", 1000, true},
+		{"1.2340", "KWD", 1234, true},
+		{"12.301", "USD", 0, false},
+		{"1000.5", "JPY", 0, false},
+		{"1.2345", "KWD", 0, false},
+		{"1000", "JPY", 1000, true},
+		{"-1000", "jpy", -1000, true},
+		{"1.234", "BHD", 1234, true},
+		{"1.2", "BHD", 1200, true},
+		{"12.", "USD", 0, false},
+		{".5", "USD", 0, false},
+		{"1,000.00", "USD", 0, false},
+		{"--1", "USD", 0, false},
+		{"abc", "USD", 0, false},
+		{"99999999999999999999", "USD", 0, false},
+	}
+	for _, test := range tests {
+		money, err := ParseMoney(test.amount, test.currency)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseMoney(%q, %s) error %v, want valid %v", test.amount, test.currency, err, test.valid)
+			continue
+		}
+		if test.valid && (money.Minor != test.minor || money.Currency != test.currency) {
+			t.Errorf("ParseMoney(%q, %s) = %+v, want %d", test.amount, test.currency, money, test.minor)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{Minor: 1230, Currency: "USD"}, "12.30"},
+		{Money{Minor: 5, Currency: "USD"}, "0.05"},
+		{Money{Minor: -5, Currency: "USD"}, "-0.05"},
+		{Money{Minor: -1230, Currency: "EUR"}, "-12.30"},
+		{Money{Minor: 0, Currency: "USD"}, "0.00"},
+		{Money{Minor: 1000, Currency: "JPY"}, "1000"},
+		{Money{Minor: -1000, Currency: "JPY"}, "-1000"},
+		{Money{Minor: 1234, Currency: "KWD"}, "1.234"},
+		{Money{Minor: -5, Currency: "KWD"}, "-0.005"},
+		{Money{Minor: 1230}, "12.30"},
+	}
+	for _, test := range tests {
+		if got := test.money.String(); got != test.want {
+			t.Errorf("%+v.String() = %q, want %q", test.money, got, test.want)
+		}
+		if parsed, err := ParseMoney(test.want, test.money.Currency); err != nil || parsed != test.money {
+			t.Errorf("ParseMoney(%q) = %+v, %v, want %+v back", test.want, parsed, err, test.money)
+		}
+	}
+}
+
+func TestMoneySplit(t *testing.T) {
+	tests := []struct {
+		minor int64
+		parts int
+		want  []int64
+	}{
+		{1000, 3, []int64{334, 333, 333}},
+		{1001, 3, []int64{334, 334, 333}},
+		{999, 3, []int64{333, 333, 333}},
+		{-1000, 3, []int64{-334, -333, -333}},
+		{-1001, 3, []int64{-334, -334, -333}},
+		{-2, 3, []int64{-1, -1, 0}},
+		{2, 3, []int64{1, 1, 0}},
+		{0, 2, []int64{0, 0}},
+		{7, 1, []int64{7}},
+		{7, 0, nil},
+		{7, -1, nil},
+	}
+	for _, test := range tests {
+		split := Money{Minor: test.minor, Currency: "USD"}.Split(test.parts)
+		if len(split) != len(test.want) {
+			t.Errorf("Split(%d, %d) = %v, want %v", test.minor, test.parts, split, test.want)
+			continue
+		}
+		sum := int64(0)
+		for index, part := range split {
+			sum += part.Minor
+			if part.Minor != test.want[index] || part.Currency != "USD" {
+				t.Errorf("Split(%d, %d) = %v, want %v", test.minor, test.parts, split, test.want)
+				break
+			}
+		}
+		if len(split) > 0 && sum != test.minor {
+			t.Errorf("Split(%d, %d) adds up to %d", test.minor, test.parts, sum)
+		}
+	}
+}
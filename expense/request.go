@@ -0,0 +1,400 @@
+package expense
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*CreateExpenseRequest - expense posted by the angular client, users as an array or flattened users__0__user_id*/
+type CreateExpenseRequest struct {
+	Cost         string         `json:"cost"`
+	CurrencyCode string         `json:"currency_code"`
+	Description  string         `json:"description"`
+	Date         string         `json:"date"`
+	CategoryID   int            `json:"category_id"`
+	GroupID      int            `json:"group_id"`
+	Users        []ExpenseShare `json:"users"`
+}
+
+/*ExpenseShare - what a single user paid and owes for an expense*/
+type ExpenseShare struct {
+	UserID    int    `json:"user_id"`
+	PaidShare string `json:"paid_share"`
+	OwedShare string `json:"owed_share"`
+}
+
+/*ValidationErrors - error messages keyed by request field*/
+type ValidationErrors map[string][]string
+
+func (validationErrors ValidationErrors) add(field string, message string) {
+	validationErrors[field] = append(validationErrors[field], message)
+}
+
+/*Error - field messages in field order, UnmarshalJSON returns ValidationErrors for fields it refuses to decode*/
+func (validationErrors ValidationErrors) Error() string {
+	fields := make([]string, 0, len(validationErrors))
+	for field, messages := range validationErrors {
+		fields = append(fields, field+" "+strings.Join(messages, ", "))
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, "; ")
+}
+
+/*flexString - json string or number*/
+type flexString string
+
+func (value *flexString) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*value = flexString(text)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("expected string or number, got %s", data)
+	}
+	*value = flexString(number.String())
+	return nil
+}
+
+/*flexInt - json number or numeric string*/
+type flexInt int
+
+func (value *flexInt) UnmarshalJSON(data []byte) error {
+	var text flexString
+	if err := text.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	if text == "" {
+		*value = 0
+		return nil
+	}
+	parsed, err := strconv.Atoi(string(text))
+	if err != nil {
+		return fmt.Errorf("expected integer, got %s", data)
+	}
+	*value = flexInt(parsed)
+	return nil
+}
+
+var flattenedUserKey = regexp.MustCompile(`^users__(\d+)__(user_id|paid_share|owed_share)$`)
+
+//maxFlattenedUsers - users__N__ indexes must stay below it, the users slice is sized by the largest index
+const maxFlattenedUsers = 100
+
+/*UnmarshalJSON - accept strings or numbers for amounts and ids, and flattened users*/
+func (request *CreateExpenseRequest) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var cost, currencyCode, description, date flexString
+	var categoryID, groupID flexInt
+	targets := map[string]json.Unmarshaler{
+		"cost":          &cost,
+		"currency_code": &currencyCode,
+		"description":   &description,
+		"date":          &date,
+		"category_id":   &categoryID,
+		"group_id":      &groupID,
+	}
+	for name, target := range targets {
+		if raw, ok := fields[name]; ok {
+			if err := target.UnmarshalJSON(raw); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+
+	*request = CreateExpenseRequest{
+		Cost:         string(cost),
+		CurrencyCode: string(currencyCode),
+		Description:  string(description),
+		Date:         string(date),
+		CategoryID:   int(categoryID),
+		GroupID:      int(groupID),
+	}
+
+	if raw, ok := fields["users"]; ok {
+		var users []struct {
+			UserID    flexInt    `json:"user_id"`
+			PaidShare flexString `json:"paid_share"`
+			OwedShare flexString `json:"owed_share"`
+		}
+		if err := json.Unmarshal(raw, &users); err != nil {
+			return fmt.Errorf("users: %v", err)
+		}
+		for _, user := range users {
+			request.Users = append(request.Users, ExpenseShare{UserID: int(user.UserID), PaidShare: string(user.PaidShare), OwedShare: string(user.OwedShare)})
+		}
+		return nil
+	}
+
+	//users__0__user_id, users__0__paid_share, ..., every user needs a key so indexes are below the number of keys
+	flattened := make(map[string][]string)
+	for name := range fields {
+		if match := flattenedUserKey.FindStringSubmatch(name); match != nil {
+			flattened[name] = match
+		}
+	}
+	validationErrors := make(ValidationErrors)
+	for name, match := range flattened {
+		index, err := strconv.Atoi(match[1])
+		if err != nil || index >= len(flattened) || index >= maxFlattenedUsers {
+			validationErrors.add(name, fmt.Sprintf("user index must be below %d and the number of users", maxFlattenedUsers))
+		}
+	}
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+	for name, match := range flattened {
+		raw := fields[name]
+		index, _ := strconv.Atoi(match[1])
+		for len(request.Users) <= index {
+			request.Users = append(request.Users, ExpenseShare{})
+		}
+		share := &request.Users[index]
+		switch match[2] {
+		case "user_id":
+			var userID flexInt
+			if err := userID.UnmarshalJSON(raw); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			share.UserID = int(userID)
+		case "paid_share", "owed_share":
+			var amount flexString
+			if err := amount.UnmarshalJSON(raw); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			if match[2] == "paid_share" {
+				share.PaidShare = string(amount)
+			} else {
+				share.OwedShare = string(amount)
+			}
+		}
+	}
+	return nil
+}
+
+//updatableFields - fields of an existing expense UpdateExpense may change besides the shares
+var updatableFields = map[string]bool{
+	"cost":          true,
+	"currency_code": true,
+	"description":   true,
+	"date":          true,
+	"category_id":   true,
+	"group_id":      true,
+}
+
+/*RequestFromExpense - create request reproducing an existing expense, updates are applied to it and validated like a new expense*/
+func RequestFromExpense(existing *Expense) CreateExpenseRequest {
+	request := CreateExpenseRequest{
+		Cost:         existing.Cost,
+		CurrencyCode: existing.CurrencyCode,
+		Description:  existing.Description,
+		CategoryID:   existing.Category.ID,
+		GroupID:      existing.GroupID,
+	}
+	if !existing.Date.IsZero() {
+		request.Date = existing.Date.Format(time.RFC3339)
+	}
+	for _, user := range existing.Users {
+		request.Users = append(request.Users, ExpenseShare{UserID: user.UserID, PaidShare: user.PaidShare, OwedShare: user.OwedShare})
+	}
+	return request
+}
+
+/*ApplyChanges - overwrite the fields present in the json object changes, shares given as users or users__N__* replace all shares. fields which cannot be updated are returned as validation errors, malformed values as error*/
+func (request *CreateExpenseRequest) ApplyChanges(changes []byte) (ValidationErrors, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(changes, &fields); err != nil {
+		return nil, err
+	}
+	var changed CreateExpenseRequest
+	if err := changed.UnmarshalJSON(changes); err != nil {
+		return nil, err
+	}
+
+	validationErrors := make(ValidationErrors)
+	sharesChanged := false
+	for name := range fields {
+		if name == "users" || flattenedUserKey.MatchString(name) {
+			sharesChanged = true
+		} else if !updatableFields[name] {
+			validationErrors.add(name, "cannot be updated")
+		}
+	}
+	if len(validationErrors) > 0 {
+		return validationErrors, nil
+	}
+
+	if _, ok := fields["cost"]; ok {
+		request.Cost = changed.Cost
+	}
+	if _, ok := fields["currency_code"]; ok {
+		request.CurrencyCode = changed.CurrencyCode
+	}
+	if _, ok := fields["description"]; ok {
+		request.Description = changed.Description
+	}
+	if _, ok := fields["date"]; ok {
+		request.Date = changed.Date
+	}
+	if _, ok := fields["category_id"]; ok {
+		request.CategoryID = changed.CategoryID
+	}
+	if _, ok := fields["group_id"]; ok {
+		request.GroupID = changed.GroupID
+	}
+	if sharesChanged {
+		request.Users = changed.Users
+	}
+	return nil, nil
+}
+
+/*Params - request in splitwise's create_expense parameter format*/
+func (request *CreateExpenseRequest) Params() map[string]interface{} {
+	params := map[string]interface{}{
+		"cost":        request.Cost,
+		"description": request.Description,
+		"group_id":    request.GroupID,
+	}
+	if request.CurrencyCode != "" {
+		params["currency_code"] = request.CurrencyCode
+	}
+	if request.Date != "" {
+		params["date"] = request.Date
+	}
+	if request.CategoryID != 0 {
+		params["category_id"] = request.CategoryID
+	}
+	for index, user := range request.Users {
+		prefix := "users__" + strconv.Itoa(index) + "__"
+		params[prefix+"user_id"] = user.UserID
+		params[prefix+"paid_share"] = user.PaidShare
+		params[prefix+"owed_share"] = user.OwedShare
+	}
+	return params
+}
+
+/*Validate - check the request against the group it is created in and the splitwise categories, returns nil when the request is valid*/
+func (request *CreateExpenseRequest) Validate(group *Group, categories []Categories) ValidationErrors {
+	validationErrors := make(ValidationErrors)
+
+	cost, err := ParseMoney(request.Cost, request.CurrencyCode)
+	if err != nil {
+		validationErrors.add("cost", err.Error())
+	} else if cost.Minor <= 0 {
+		validationErrors.add("cost", "must be greater than 0")
+	}
+
+	if request.CurrencyCode != "" && !IsCurrencyCode(request.CurrencyCode) {
+		validationErrors.add("currency_code", "must be a three letter ISO currency code")
+	}
+
+	if strings.TrimSpace(request.Description) == "" {
+		validationErrors.add("description", "is required")
+	}
+
+	if request.Date != "" && !validDate(request.Date) {
+		validationErrors.add("date", "must be an ISO-8601 date")
+	}
+
+	if request.CategoryID != 0 && !CategoryExists(categories, request.CategoryID) {
+		validationErrors.add("category_id", "unknown category")
+	}
+
+	if request.GroupID == 0 || group == nil || group.ID != request.GroupID {
+		validationErrors.add("group_id", "unknown group")
+	}
+
+	if len(request.Users) == 0 {
+		validationErrors.add("users", "at least one user is required")
+	}
+
+	paidTotal := Money{Currency: request.CurrencyCode}
+	owedTotal := Money{Currency: request.CurrencyCode}
+	sharesValid := true
+	seen := make(map[int]bool)
+	for index, user := range request.Users {
+		prefix := "users__" + strconv.Itoa(index) + "__"
+		if group != nil && !group.HasMember(user.UserID) {
+			validationErrors.add(prefix+"user_id", "is not a member of the group")
+		}
+		if seen[user.UserID] {
+			validationErrors.add(prefix+"user_id", "appears more than once")
+		}
+		seen[user.UserID] = true
+
+		paid, err := ParseMoney(user.PaidShare, request.CurrencyCode)
+		if err != nil {
+			validationErrors.add(prefix+"paid_share", err.Error())
+			sharesValid = false
+		}
+		owed, err := ParseMoney(user.OwedShare, request.CurrencyCode)
+		if err != nil {
+			validationErrors.add(prefix+"owed_share", err.Error())
+			sharesValid = false
+		}
+		paidTotal.Minor += paid.Minor
+		owedTotal.Minor += owed.Minor
+	}
+
+	if sharesValid && cost.Minor > 0 && len(request.Users) > 0 {
+		if paidTotal.Minor != cost.Minor {
+			validationErrors.add("users", "paid shares must add up to the cost")
+		}
+		if owedTotal.Minor != cost.Minor {
+			validationErrors.add("users", "owed shares must add up to the cost")
+		}
+	}
+
+	if len(validationErrors) == 0 {
+		return nil
+	}
+	return validationErrors
+}
+
+/*HasMember - whether userID is a member of the group*/
+func (group *Group) HasMember(userID int) bool {
+	for _, member := range group.Members {
+		if member.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+/*CategoryExists - whether categoryID is a category or subcategory*/
+func CategoryExists(categories []Categories, categoryID int) bool {
+	for _, category := range categories {
+		if category.ID == categoryID {
+			return true
+		}
+		for _, subcategory := range category.Subcategories {
+			if subcategory.ID == categoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validDate(date string) bool {
+	_, err := ParseDate(date)
+	return err == nil
+}
+
+/*ParseDate - expense date as RFC3339 or 2006-01-02*/
+func ParseDate(date string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, date); err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", date)
+}
@@ -0,0 +1,54 @@
+package expense
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalFlattenedUsers(t *testing.T) {
+	var request CreateExpenseRequest
+	body := `{"cost": "30", "users__1__user_id": 2, "users__1__owed_share": "15",
+		"users__0__user_id": "1", "users__0__paid_share": 30, "users__0__owed_share": "15"}`
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatal(err)
+	}
+	want := []ExpenseShare{{UserID: 1, PaidShare: "30", OwedShare: "15"}, {UserID: 2, OwedShare: "15"}}
+	if len(request.Users) != len(want) || request.Users[0] != want[0] || request.Users[1] != want[1] {
+		t.Errorf("users = %+v, want %+v", request.Users, want)
+	}
+}
+
+func TestUnmarshalRejectsUserIndexes(t *testing.T) {
+	bodies := map[string]string{
+		"huge index":        `{"users__100000000__user_id": 1}`,
+		"above the cap":     `{"users__100__user_id": 1}`,
+		"beyond the keys":   `{"users__0__user_id": 1, "users__5__user_id": 2}`,
+		"overflowing index": `{"users__99999999999999999999__user_id": 1}`,
+	}
+	for name, body := range bodies {
+		var request CreateExpenseRequest
+		err := json.Unmarshal([]byte(body), &request)
+		if _, ok := err.(ValidationErrors); !ok {
+			t.Errorf("%s: err = %v, want validation errors", name, err)
+		}
+		if len(request.Users) != 0 {
+			t.Errorf("%s: %d users were allocated", name, len(request.Users))
+		}
+	}
+}
+
+func TestValidateRejectsDuplicateUsers(t *testing.T) {
+	group := &Group{ID: 100, Members: []Members{{ID: 1}, {ID: 2}}}
+	request := CreateExpenseRequest{Cost: "30", Description: "Pizza", GroupID: 100, Users: []ExpenseShare{
+		{UserID: 1, PaidShare: "30", OwedShare: "15"},
+		{UserID: 1, OwedShare: "15"},
+	}}
+
+	validationErrors := request.Validate(group, nil)
+	if len(validationErrors["users__1__user_id"]) == 0 {
+		t.Errorf("errors = %v, want the repeated user reported", validationErrors)
+	}
+	if len(validationErrors["users__0__user_id"]) != 0 {
+		t.Errorf("first appearance of the user is reported: %v", validationErrors["users__0__user_id"])
+	}
+}
@@ -0,0 +1,36 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+/*WriteCSV - table as RFC 4180 csv*/
+func WriteCSV(w io.Writer, table *Table) error {
+	writer := csv.NewWriter(w)
+	//member names are headers too
+	header := make([]string, len(table.Header))
+	for index, title := range table.Header {
+		header[index] = safeText(title)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range append(append([][]Cell(nil), table.Rows...), table.Totals...) {
+		record := make([]string, len(row))
+		for index, cell := range row {
+			if cell.Numeric {
+				record[index] = cell.Value
+			} else {
+				record[index] = safeText(cell.Value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
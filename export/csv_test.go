@@ -0,0 +1,32 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	table := &Table{
+		Header: []string{"Date", "=HYPERLINK(\"http://evil\")"},
+		Rows:   [][]Cell{{{Value: "@SUM(A1)"}, {Value: "-12.50", Numeric: true}}},
+	}
+	var buffer bytes.Buffer
+	if err := WriteCSV(&buffer, table); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := records[0][1]; header != "'=HYPERLINK(\"http://evil\")" {
+		t.Errorf("member header %q was not escaped", header)
+	}
+	if text := records[1][0]; text != "'@SUM(A1)" {
+		t.Errorf("text cell %q was not escaped", text)
+	}
+	if number := records[1][1]; number != "-12.50" {
+		t.Errorf("numeric cell %q was changed", number)
+	}
+}
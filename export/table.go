@@ -0,0 +1,138 @@
+package export
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+/*Cell - a spreadsheet cell, Numeric cells are written as numbers in xlsx*/
+type Cell struct {
+	Value   string
+	Numeric bool
+}
+
+/*Table - header and rows of an export, Totals are appended after the rows*/
+type Table struct {
+	Header []string
+	Rows   [][]Cell
+	Totals [][]Cell
+}
+
+//fixed columns before one column per member
+var fixedColumns = []string{"Date", "Description", "Category", "Currency", "Cost"}
+
+/*ExpenseTable - one row per non deleted expense with each member's owed share in its own column, followed by a totals row per currency*/
+func ExpenseTable(expenses []expense.Expense, members []expense.Members) (*Table, error) {
+	memberIDs, memberNames := memberColumns(expenses, members)
+
+	table := &Table{Header: append([]string(nil), fixedColumns...)}
+	for _, memberID := range memberIDs {
+		table.Header = append(table.Header, memberNames[memberID])
+	}
+
+	type totals struct {
+		cost    expense.Money
+		members map[int]expense.Money
+	}
+	currencyTotals := make(map[string]*totals)
+
+	for _, individualExpense := range expenses {
+		if !individualExpense.DeletedAt.IsZero() {
+			continue
+		}
+		currencyCode := individualExpense.CurrencyCode
+
+		total, ok := currencyTotals[currencyCode]
+		if !ok {
+			total = &totals{cost: expense.Money{Currency: currencyCode}, members: make(map[int]expense.Money)}
+			currencyTotals[currencyCode] = total
+		}
+
+		owedShares := make(map[int]expense.Money)
+		cost := expense.Money{Currency: currencyCode}
+		for _, userInfo := range individualExpense.Users {
+			owed, err := userInfo.Owed(currencyCode)
+			if err != nil {
+				return nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+			}
+			owedShares[userInfo.UserID], _ = owedShares[userInfo.UserID].Add(owed)
+			total.members[userInfo.UserID], _ = total.members[userInfo.UserID].Add(owed)
+			cost, _ = cost.Add(owed)
+		}
+		if individualExpense.Cost != "" {
+			parsed, err := expense.ParseMoney(individualExpense.Cost, currencyCode)
+			if err != nil {
+				return nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+			}
+			cost = parsed
+		}
+		total.cost, _ = total.cost.Add(cost)
+
+		row := []Cell{
+			{Value: individualExpense.Date.Format("2006-01-02")},
+			{Value: individualExpense.Description},
+			{Value: individualExpense.Category.Name},
+			{Value: currencyCode},
+			{Value: cost.String(), Numeric: true},
+		}
+		for _, memberID := range memberIDs {
+			row = append(row, amountCell(owedShares[memberID], currencyCode))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	currencies := make([]string, 0, len(currencyTotals))
+	for currencyCode := range currencyTotals {
+		currencies = append(currencies, currencyCode)
+	}
+	sort.Strings(currencies)
+	for _, currencyCode := range currencies {
+		total := currencyTotals[currencyCode]
+		row := []Cell{{Value: "Total"}, {}, {}, {Value: currencyCode}, {Value: total.cost.String(), Numeric: true}}
+		for _, memberID := range memberIDs {
+			row = append(row, amountCell(total.members[memberID], currencyCode))
+		}
+		table.Totals = append(table.Totals, row)
+	}
+	return table, nil
+}
+
+func amountCell(amount expense.Money, currencyCode string) Cell {
+	amount.Currency = currencyCode
+	return Cell{Value: amount.String(), Numeric: true}
+}
+
+/*memberColumns - group members in group order followed by anyone else sharing an expense*/
+func memberColumns(expenses []expense.Expense, members []expense.Members) ([]int, map[int]string) {
+	memberIDs := make([]int, 0, len(members))
+	memberNames := make(map[int]string)
+	for _, member := range members {
+		memberIDs = append(memberIDs, member.ID)
+		memberNames[member.ID] = member.FirstName
+	}
+
+	others := make([]int, 0)
+	for _, individualExpense := range expenses {
+		for _, userInfo := range individualExpense.Users {
+			if _, ok := memberNames[userInfo.UserID]; !ok {
+				memberNames[userInfo.UserID] = "User " + strconv.Itoa(userInfo.UserID)
+				others = append(others, userInfo.UserID)
+			}
+		}
+	}
+	sort.Ints(others)
+	return append(memberIDs, others...), memberNames
+}
+
+/*safeText - keep spreadsheet applications from evaluating text cells as formulas*/
+func safeText(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//static parts of a single sheet workbook
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Expenses" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="#,##0.00"/></numFmts><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/><xf numFmtId="164" fontId="1" fillId="0" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1"/></cellXfs></styleSheet>`},
+}
+
+//cell styles from xl/styles.xml
+const (
+	styleText       = 0
+	styleAmount     = 1
+	styleBoldText   = 2
+	styleBoldAmount = 3
+)
+
+/*WriteXLSX - table as a single sheet office open xml workbook, header and totals in bold*/
+func WriteXLSX(w io.Writer, table *Table) error {
+	archive := zip.NewWriter(w)
+
+	for _, part := range xlsxParts {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheetWriter, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if err := writeSheet(sheetWriter, table); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func writeSheet(w io.Writer, table *Table) error {
+	sheet := bufio.NewWriter(w)
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	rowNumber := 1
+	header := make([]Cell, len(table.Header))
+	for index, title := range table.Header {
+		header[index] = Cell{Value: title}
+	}
+	writeRow(sheet, rowNumber, header, true)
+
+	for _, row := range table.Rows {
+		rowNumber++
+		writeRow(sheet, rowNumber, row, false)
+	}
+	for _, row := range table.Totals {
+		rowNumber++
+		writeRow(sheet, rowNumber, row, true)
+	}
+
+	sheet.WriteString(`</sheetData></worksheet>`)
+	return sheet.Flush()
+}
+
+func writeRow(sheet *bufio.Writer, rowNumber int, row []Cell, bold bool) {
+	sheet.WriteString(`<row r="` + strconv.Itoa(rowNumber) + `">`)
+	for index, cell := range row {
+		reference := columnName(index) + strconv.Itoa(rowNumber)
+		if cell.Numeric {
+			style := styleAmount
+			if bold {
+				style = styleBoldAmount
+			}
+			sheet.WriteString(`<c r="` + reference + `" s="` + strconv.Itoa(style) + `"><v>` + cell.Value + `</v></c>`)
+			continue
+		}
+
+		style := styleText
+		if bold {
+			style = styleBoldText
+		}
+		var escaped strings.Builder
+		xml.EscapeText(&escaped, []byte(cell.Value))
+		sheet.WriteString(`<c r="` + reference + `" s="` + strconv.Itoa(style) + `" t="inlineStr"><is><t xml:space="preserve">` + escaped.String() + `</t></is></c>`)
+	}
+	sheet.WriteString(`</row>`)
+}
+
+/*columnName - spreadsheet column letters for a zero based index, 0 -> A, 26 -> AA*/
+func columnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,23 @@
+package filelock
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+/*Locked - run fn holding the os lock on path+".lock", exclusive for writers and shared for readers*/
+func Locked(path string, exclusive bool, fn func() error) error {
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return errors.Wrap(err, "error opening lock file")
+	}
+	defer lock.Close()
+
+	if err := Lock(lock, exclusive); err != nil {
+		return errors.Wrap(err, "error locking "+path)
+	}
+	defer Unlock(lock)
+
+	return fn()
+}
@@ -0,0 +1,13 @@
+package filelock
+
+import "os"
+
+/*Lock - windows has no flock, file backed stores are only safe for a single process there*/
+func Lock(file *os.File, exclusive bool) error {
+	return nil
+}
+
+/*Unlock - release the lock taken with Lock*/
+func Unlock(file *os.File) error {
+	return nil
+}
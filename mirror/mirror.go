@@ -0,0 +1,289 @@
+package mirror
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/splitwise"
+
+	"github.com/pkg/errors"
+)
+
+//syncOverlap - incremental syncs re-read this much before the newest change seen, against clock skew and
+//changes within the same second, expenses are upserted so reading one twice is harmless
+const syncOverlap = time.Minute
+
+/*Source - where the mirror pulls groups and expenses from, a splitwise.Client*/
+type Source interface {
+	//RefreshGroups - current groups, never from a cache
+	RefreshGroups() ([]expense.Group, error)
+	GetExpenses(query splitwise.ExpensesQuery) ([]expense.Expense, error)
+}
+
+/*userMirror - everything mirrored for one user, also the file format*/
+type userMirror struct {
+	Groups   []expense.Group         `json:"groups"`
+	Expenses map[int]expense.Expense `json:"expenses"`
+	//HighWater - newest UpdatedAt seen, the next sync asks for changes after it
+	HighWater time.Time `json:"high_water"`
+	//SyncedAt - when the last successful sync started, the data is at least this recent
+	SyncedAt time.Time `json:"synced_at"`
+	//StaleAt - when the user last changed an expense through the api
+	StaleAt     time.Time `json:"stale_at"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+/*Freshness - how current a user's mirror is*/
+type Freshness struct {
+	SyncedAt   time.Time `json:"synced_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+	//Stale - older than the allowed age or changed since the last sync
+	Stale       bool      `json:"stale"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	Groups      int       `json:"groups"`
+	Expenses    int       `json:"expenses"`
+}
+
+/*SyncResult - what a sync changed*/
+type SyncResult struct {
+	Full    bool `json:"full"`
+	Updated int  `json:"updated"`
+	Deleted int  `json:"deleted"`
+}
+
+/*Mirror - local copy of the groups and expenses of every user, one json file per user in dir*/
+type Mirror struct {
+	mutex     sync.Mutex
+	dir       string
+	users     map[string]*userMirror
+	syncLocks map[string]*sync.Mutex
+	now       func() time.Time
+}
+
+/*NewMemory - mirror which is lost on restart*/
+func NewMemory() *Mirror {
+	return &Mirror{users: make(map[string]*userMirror), syncLocks: make(map[string]*sync.Mutex), now: time.Now}
+}
+
+/*New - mirror kept in dir, in memory only when dir is empty*/
+func New(dir string) (*Mirror, error) {
+	mirror := NewMemory()
+	if dir == "" {
+		return mirror, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "error creating mirror directory")
+	}
+	mirror.dir = dir
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing mirror")
+	}
+	for _, file := range files {
+		user, err := url.PathUnescape(filepath.Base(file[:len(file)-len(".json")]))
+		if err != nil {
+			continue
+		}
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading mirror")
+		}
+		state := new(userMirror)
+		if err := json.Unmarshal(contents, state); err != nil {
+			return nil, errors.Wrap(err, "error parsing mirror of user "+user)
+		}
+		if state.Expenses == nil {
+			state.Expenses = make(map[int]expense.Expense)
+		}
+		mirror.users[user] = state
+	}
+	return mirror, nil
+}
+
+/*Users - users with a mirror*/
+func (mirror *Mirror) Users() []string {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	users := make([]string, 0, len(mirror.users))
+	for user := range mirror.users {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users
+}
+
+/*Sync - pull the user's groups and the expenses changed since the last sync, everything on the first sync*/
+func (mirror *Mirror) Sync(user string, source Source) (SyncResult, error) {
+	lock := mirror.syncLock(user)
+	lock.Lock()
+	defer lock.Unlock()
+
+	started := mirror.now()
+	mirror.mutex.Lock()
+	state := mirror.state(user)
+	query := splitwise.ExpensesQuery{}
+	if !state.HighWater.IsZero() {
+		query.UpdatedAfter = state.HighWater.Add(-syncOverlap)
+	}
+	mirror.mutex.Unlock()
+
+	groups, err := source.RefreshGroups()
+	var changed []expense.Expense
+	if err == nil {
+		changed, err = source.GetExpenses(query)
+	}
+
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	state.LastAttempt = started
+	if err != nil {
+		state.LastError = err.Error()
+		if saveErr := mirror.save(user, state); saveErr != nil {
+			return SyncResult{}, saveErr
+		}
+		return SyncResult{}, err
+	}
+
+	result := SyncResult{Full: query.UpdatedAfter.IsZero()}
+	for _, individualExpense := range changed {
+		state.Expenses[individualExpense.ID] = individualExpense
+		if individualExpense.UpdatedAt.After(state.HighWater) {
+			state.HighWater = individualExpense.UpdatedAt
+		}
+		if individualExpense.DeletedAt.IsZero() {
+			result.Updated++
+		} else {
+			result.Deleted++
+		}
+	}
+
+	//drop expenses of groups the user left, expenses outside groups have group id 0
+	memberOf := map[int]bool{0: true}
+	for _, group := range groups {
+		memberOf[group.ID] = true
+	}
+	for expenseID, individualExpense := range state.Expenses {
+		if !memberOf[individualExpense.GroupID] {
+			delete(state.Expenses, expenseID)
+		}
+	}
+
+	state.Groups = groups
+	state.SyncedAt = started
+	state.LastError = ""
+	return result, mirror.save(user, state)
+}
+
+/*MarkStale - the user changed expenses, the next read should sync first*/
+func (mirror *Mirror) MarkStale(user string) {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	if state, ok := mirror.users[user]; ok {
+		state.StaleAt = mirror.now()
+	}
+}
+
+/*Freshness - how current the user's mirror is given maxAge, false when it was never synced*/
+func (mirror *Mirror) Freshness(user string, maxAge time.Duration) (Freshness, bool) {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	state, ok := mirror.users[user]
+	if !ok || state.SyncedAt.IsZero() {
+		return Freshness{}, false
+	}
+	age := mirror.now().Sub(state.SyncedAt)
+	return Freshness{
+		SyncedAt:    state.SyncedAt,
+		AgeSeconds:  int64(age.Seconds()),
+		Stale:       age > maxAge || !state.SyncedAt.After(state.StaleAt),
+		LastAttempt: state.LastAttempt,
+		LastError:   state.LastError,
+		Groups:      len(state.Groups),
+		Expenses:    len(state.Expenses),
+	}, true
+}
+
+/*Expenses - mirrored expenses of a group dated in [start, end) newest first, deleted ones included like splitwise does*/
+func (mirror *Mirror) Expenses(user string, groupID int, start time.Time, end time.Time) []expense.Expense {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	expenses := make([]expense.Expense, 0)
+	state, ok := mirror.users[user]
+	if !ok {
+		return expenses
+	}
+	for _, individualExpense := range state.Expenses {
+		if groupID != 0 && individualExpense.GroupID != groupID {
+			continue
+		}
+		if !start.IsZero() && individualExpense.Date.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !individualExpense.Date.Before(end) {
+			continue
+		}
+		expenses = append(expenses, individualExpense)
+	}
+	sort.Slice(expenses, func(i, j int) bool {
+		if !expenses[i].Date.Equal(expenses[j].Date) {
+			return expenses[i].Date.After(expenses[j].Date)
+		}
+		return expenses[i].ID > expenses[j].ID
+	})
+	return expenses
+}
+
+/*state - the user's mirror, created empty on first use, mirror.mutex must be held*/
+func (mirror *Mirror) state(user string) *userMirror {
+	state, ok := mirror.users[user]
+	if !ok {
+		state = &userMirror{Expenses: make(map[int]expense.Expense)}
+		mirror.users[user] = state
+	}
+	return state
+}
+
+func (mirror *Mirror) syncLock(user string) *sync.Mutex {
+	mirror.mutex.Lock()
+	defer mirror.mutex.Unlock()
+
+	lock, ok := mirror.syncLocks[user]
+	if !ok {
+		lock = new(sync.Mutex)
+		mirror.syncLocks[user] = lock
+	}
+	return lock
+}
+
+/*save - write the user's mirror through a temp file, mirror.mutex must be held*/
+func (mirror *Mirror) save(user string, state *userMirror) error {
+	if mirror.dir == "" {
+		return nil
+	}
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "error encoding mirror")
+	}
+
+	path := filepath.Join(mirror.dir, url.PathEscape(user)+".json")
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, contents, 0600); err != nil {
+		return errors.Wrap(err, "error writing mirror")
+	}
+	return errors.Wrap(os.Rename(tempPath, path), "error writing mirror")
+}
@@ -0,0 +1,98 @@
+package recurring
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const dateLayout = "2006-01-02"
+
+/*Schedule - when a recurring expense is due, every Interval months on DayOfMonth or every Interval weeks from StartDate*/
+type Schedule struct {
+	//Frequency - monthly or weekly
+	Frequency string `json:"frequency"`
+	//Interval - months or weeks between due dates, 1 when 0
+	Interval int `json:"interval"`
+	//DayOfMonth - day of monthly expenses, the last day in shorter months, StartDate's day when 0
+	DayOfMonth int    `json:"day_of_month,omitempty"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date,omitempty"`
+}
+
+/*Validate - check the schedule can produce dates*/
+func (schedule *Schedule) Validate() error {
+	if schedule.Frequency != "monthly" && schedule.Frequency != "weekly" {
+		return errors.New("frequency must be monthly or weekly")
+	}
+	if schedule.Interval < 0 {
+		return errors.New("interval must be 0 or more, 0 means 1")
+	}
+	if schedule.DayOfMonth < 0 || schedule.DayOfMonth > 31 {
+		return errors.New("day_of_month must be between 0 and 31, 0 means start_date's day")
+	}
+	start, err := time.Parse(dateLayout, schedule.StartDate)
+	if err != nil {
+		return errors.New("start_date must be a 2006-01-02 date")
+	}
+	if schedule.EndDate != "" {
+		end, err := time.Parse(dateLayout, schedule.EndDate)
+		if err != nil {
+			return errors.New("end_date must be a 2006-01-02 date")
+		}
+		if end.Before(start) {
+			return errors.New("end_date is before start_date")
+		}
+	}
+	return nil
+}
+
+/*Occurrences - due dates between from and to inclusive, dates are midnight in location*/
+func (schedule *Schedule) Occurrences(from time.Time, to time.Time, location *time.Location) []time.Time {
+	start, err := time.ParseInLocation(dateLayout, schedule.StartDate, location)
+	if err != nil {
+		return nil
+	}
+	end := to
+	if schedule.EndDate != "" {
+		if scheduleEnd, err := time.ParseInLocation(dateLayout, schedule.EndDate, location); err == nil && scheduleEnd.Before(end) {
+			end = scheduleEnd
+		}
+	}
+	interval := schedule.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	var occurrences []time.Time
+	for count := 0; ; count++ {
+		var date time.Time
+		if schedule.Frequency == "weekly" {
+			date = start.AddDate(0, 0, 7*interval*count)
+		} else {
+			date = monthlyDate(start, count*interval, schedule.DayOfMonth)
+			if date.Before(start) {
+				continue
+			}
+		}
+		if date.After(end) {
+			return occurrences
+		}
+		if !date.Before(from) {
+			occurrences = append(occurrences, date)
+		}
+	}
+}
+
+/*monthlyDate - day of the month months after start in start's location, clamped to the month's last day*/
+func monthlyDate(start time.Time, months int, day int) time.Time {
+	if day == 0 {
+		day = start.Day()
+	}
+	firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, start.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
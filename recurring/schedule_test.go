@@ -0,0 +1,82 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueInOwnersTimeZone(t *testing.T) {
+	tests := []struct {
+		timeZone string
+		now      time.Time
+		due      int
+	}{
+		//past midnight of the 1st in Auckland while still April 30 in UTC
+		{"Pacific/Auckland", time.Date(2019, 4, 30, 13, 0, 0, 0, time.UTC), 1},
+		//May 1 in UTC while still April 30 in New York
+		{"America/New_York", time.Date(2019, 5, 1, 2, 0, 0, 0, time.UTC), 0},
+		{"America/New_York", time.Date(2019, 5, 1, 4, 0, 0, 0, time.UTC), 1},
+		{"", time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), 1},
+	}
+	for _, test := range tests {
+		template := Template{
+			TimeZone:  test.timeZone,
+			Schedule:  Schedule{Frequency: "monthly", DayOfMonth: 1, StartDate: "2019-05-01"},
+			CreatedAt: time.Date(2019, 4, 20, 0, 0, 0, 0, time.UTC),
+		}
+		due := template.Due(test.now)
+		if len(due) != test.due {
+			t.Errorf("%q at %s: due %v, want %d dates", test.timeZone, test.now, due, test.due)
+			continue
+		}
+		if len(due) == 1 && (due[0].Location().String() != template.Location().String() || due[0].Format(dateLayout) != "2019-05-01" || due[0].Hour() != 0) {
+			t.Errorf("%q: due %s, want midnight of 2019-05-01 in the owner's time zone", test.timeZone, due[0])
+		}
+	}
+}
+
+func TestFirstDueInOwnersTimeZone(t *testing.T) {
+	//created on May 1 in Auckland, the run of May 1 is not caught up
+	template := Template{
+		TimeZone:  "Pacific/Auckland",
+		Schedule:  Schedule{Frequency: "weekly", StartDate: "2019-04-24"},
+		CreatedAt: time.Date(2019, 4, 30, 20, 0, 0, 0, time.UTC),
+	}
+	if first := template.FirstDue(); first.Format(dateLayout) != "2019-05-01" {
+		t.Errorf("first due %s, want 2019-05-01", first)
+	}
+	if due := template.Due(time.Date(2019, 5, 2, 0, 0, 0, 0, time.UTC)); len(due) != 1 || due[0].Format(dateLayout) != "2019-05-01" {
+		t.Errorf("due %v, want only 2019-05-01", due)
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		valid    bool
+	}{
+		{Schedule{Frequency: "monthly", StartDate: "2019-05-31"}, true},
+		{Schedule{Frequency: "monthly", Interval: 2, DayOfMonth: 31, StartDate: "2019-05-01"}, true},
+		{Schedule{Frequency: "monthly", Interval: -1, StartDate: "2019-05-01"}, false},
+		{Schedule{Frequency: "monthly", DayOfMonth: -1, StartDate: "2019-05-01"}, false},
+		{Schedule{Frequency: "monthly", DayOfMonth: 32, StartDate: "2019-05-01"}, false},
+	}
+	for _, test := range tests {
+		if err := test.schedule.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v: error %v, want valid %v", test.schedule, err, test.valid)
+		}
+	}
+
+	//the defaults are every month on start_date's day
+	schedule := Schedule{Frequency: "monthly", StartDate: "2019-05-31"}
+	due := schedule.Occurrences(time.Time{}, time.Date(2019, 7, 31, 0, 0, 0, 0, time.UTC), time.UTC)
+	want := []string{"2019-05-31", "2019-06-30", "2019-07-31"}
+	if len(due) != len(want) {
+		t.Fatalf("due %v, want %v", due, want)
+	}
+	for index, date := range due {
+		if date.Format(dateLayout) != want[index] {
+			t.Errorf("due %v, want %v", due, want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package recurring
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+	"splitwiseAngularAPI/filelock"
+
+	"github.com/pkg/errors"
+)
+
+//run statuses, a pending run was started but its result was never recorded
+const (
+	RunPending   = "pending"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+/*Template - expense created by the scheduler on every due date of Schedule*/
+type Template struct {
+	ID       int      `json:"id"`
+	Owner    string   `json:"owner"`
+	Name     string   `json:"name"`
+	Schedule Schedule `json:"schedule"`
+	//Expense - created with the due date as its date
+	Expense   expense.CreateExpenseRequest `json:"expense"`
+	CreatedAt time.Time                    `json:"created_at"`
+	//TimeZone - of the owner's splitwise profile, due dates start at midnight in it
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+/*Location - time zone of the due dates, UTC when it is unknown*/
+func (template *Template) Location() *time.Location {
+	location, err := time.LoadLocation(template.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+/*FirstDue - earliest date runs are caught up from, dates before the template was created are never posted*/
+func (template *Template) FirstDue() time.Time {
+	created := template.CreatedAt.In(template.Location())
+	return time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, created.Location())
+}
+
+/*Due - dates of the template due by now, midnight in the owner's time zone*/
+func (template *Template) Due(now time.Time) []time.Time {
+	return template.Schedule.Occurrences(template.FirstDue(), now, template.Location())
+}
+
+/*RunKey - identifies the run of template for date, at most one expense is posted per key*/
+func (template *Template) RunKey(date time.Time) string {
+	return strconv.Itoa(template.ID) + ":" + date.Format(dateLayout)
+}
+
+/*Run - history of posting a template's expense for one due date*/
+type Run struct {
+	Key          string    `json:"key"`
+	TemplateID   int       `json:"template_id"`
+	Owner        string    `json:"owner"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	RanAt        time.Time `json:"ran_at"`
+	ExpenseID    int       `json:"expense_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+/*Credentials - oauth token the scheduler uses on behalf of an owner*/
+type Credentials struct {
+	Token       string `json:"token"`
+	TokenSecret string `json:"token_secret"`
+}
+
+/*storeContents - everything the store keeps, also its file format*/
+type storeContents struct {
+	NextID    int                    `json:"next_id"`
+	Templates []Template             `json:"templates"`
+	Runs      map[string]Run         `json:"runs"`
+	Owners    map[string]Credentials `json:"owners"`
+}
+
+/*clone - copy whose slices and maps can be changed without touching contents*/
+func (contents storeContents) clone() storeContents {
+	cloned := storeContents{
+		NextID:    contents.NextID,
+		Templates: append([]Template(nil), contents.Templates...),
+		Runs:      make(map[string]Run, len(contents.Runs)+1),
+		Owners:    make(map[string]Credentials, len(contents.Owners)+1),
+	}
+	for key, run := range contents.Runs {
+		cloned.Runs[key] = run
+	}
+	for owner, credentials := range contents.Owners {
+		cloned.Owners[owner] = credentials
+	}
+	return cloned
+}
+
+func newContents() storeContents {
+	return storeContents{NextID: 1, Runs: make(map[string]Run), Owners: make(map[string]Credentials)}
+}
+
+//errUnchanged - an update decided not to change anything
+var errUnchanged = errors.New("unchanged")
+
+/*Store - templates, run history and owner credentials. A store with a path reads and writes its file under an os lock on every call, so replicas sharing the file see each other's changes and never claim the same run. The mutex orders goroutines of this process*/
+type Store struct {
+	mutex sync.Mutex
+	path  string
+	//contents - everything of a store without path
+	contents storeContents
+}
+
+/*NewMemoryStore - store which loses everything on restart*/
+func NewMemoryStore() *Store {
+	return &Store{contents: newContents()}
+}
+
+/*NewStore - store backed by the json file at path, in memory only when path is empty*/
+func NewStore(path string) (*Store, error) {
+	store := NewMemoryStore()
+	if path == "" {
+		return store, nil
+	}
+	store.path = path
+
+	//make sure the file is readable before accepting templates
+	if err := store.view(func(*storeContents) {}); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+/*Templates - templates of owner, all templates when owner is empty*/
+func (store *Store) Templates(owner string) ([]Template, error) {
+	templates := make([]Template, 0)
+	err := store.view(func(contents *storeContents) {
+		for _, template := range contents.Templates {
+			if owner == "" || template.Owner == owner {
+				templates = append(templates, template)
+			}
+		}
+	})
+	return templates, err
+}
+
+/*AddTemplate - save template with a new id and the credentials the owner's expenses are posted with*/
+func (store *Store) AddTemplate(template Template, credentials Credentials) (Template, error) {
+	err := store.update(func(contents *storeContents) error {
+		template.ID = contents.NextID
+		contents.NextID++
+		contents.Templates = append(contents.Templates, template)
+		contents.Owners[template.Owner] = credentials
+		return nil
+	})
+	if err != nil {
+		return Template{}, err
+	}
+	return template, nil
+}
+
+/*DeleteTemplate - remove template id of owner, its run history is kept*/
+func (store *Store) DeleteTemplate(owner string, id int) (bool, error) {
+	err := store.update(func(contents *storeContents) error {
+		for index, template := range contents.Templates {
+			if template.ID == id && template.Owner == owner {
+				contents.Templates = append(contents.Templates[:index], contents.Templates[index+1:]...)
+				return nil
+			}
+		}
+		return errUnchanged
+	})
+	if err == errUnchanged {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+/*Credentials - token for owner*/
+func (store *Store) Credentials(owner string) (Credentials, bool, error) {
+	var credentials Credentials
+	var ok bool
+	err := store.view(func(contents *storeContents) {
+		credentials, ok = contents.Owners[owner]
+	})
+	return credentials, ok, err
+}
+
+/*UpdateCredentials - replace the token of an owner who already has templates*/
+func (store *Store) UpdateCredentials(owner string, credentials Credentials) error {
+	err := store.update(func(contents *storeContents) error {
+		if existing, ok := contents.Owners[owner]; !ok || existing == credentials {
+			return errUnchanged
+		}
+		contents.Owners[owner] = credentials
+		return nil
+	})
+	if err == errUnchanged {
+		return nil
+	}
+	return err
+}
+
+/*Run - run with key*/
+func (store *Store) Run(key string) (Run, bool, error) {
+	var run Run
+	var ok bool
+	err := store.view(func(contents *storeContents) {
+		run, ok = contents.Runs[key]
+	})
+	return run, ok, err
+}
+
+/*SaveRun - save or replace the run with the same key*/
+func (store *Store) SaveRun(run Run) error {
+	return store.update(func(contents *storeContents) error {
+		contents.Runs[run.Key] = run
+		return nil
+	})
+}
+
+/*ClaimRun - start an attempt of run.Key unless the run succeeded, had maxAttempts already or another attempt started less than lease before now. The claimed run is saved pending with one more attempt so no other process starts one meanwhile. previous is the run before the claim, its Key is empty when there was none*/
+func (store *Store) ClaimRun(run Run, now time.Time, maxAttempts int, lease time.Duration) (claimed Run, previous Run, ok bool, err error) {
+	err = store.update(func(contents *storeContents) error {
+		if existing, exists := contents.Runs[run.Key]; exists {
+			busy := existing.Status == RunPending && now.Sub(existing.RanAt) < lease
+			if existing.Status == RunSucceeded || existing.Attempts >= maxAttempts || busy {
+				return errUnchanged
+			}
+			previous = existing
+			run = existing
+		}
+		run.Status = RunPending
+		run.Attempts++
+		run.RanAt = now
+		contents.Runs[run.Key] = run
+		return nil
+	})
+	if err == errUnchanged {
+		return Run{}, Run{}, false, nil
+	}
+	if err != nil {
+		return Run{}, Run{}, false, err
+	}
+	return run, previous, true, nil
+}
+
+/*Runs - run history of owner newest first, only of templateID when it is not 0*/
+func (store *Store) Runs(owner string, templateID int) ([]Run, error) {
+	runs := make([]Run, 0)
+	err := store.view(func(contents *storeContents) {
+		for _, run := range contents.Runs {
+			if run.Owner == owner && (templateID == 0 || run.TemplateID == templateID) {
+				runs = append(runs, run)
+			}
+		}
+	})
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].ScheduledFor.Equal(runs[j].ScheduledFor) {
+			return runs[i].ScheduledFor.After(runs[j].ScheduledFor)
+		}
+		return runs[i].TemplateID < runs[j].TemplateID
+	})
+	return runs, err
+}
+
+/*view - run fn on the current contents, under a shared lock of the file*/
+func (store *Store) view(fn func(contents *storeContents)) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.path == "" {
+		fn(&store.contents)
+		return nil
+	}
+	return filelock.Locked(store.path, false, func() error {
+		contents, err := store.load()
+		if err != nil {
+			return err
+		}
+		fn(&contents)
+		return nil
+	})
+}
+
+/*update - read, change and write the contents under an exclusive lock of the file, nothing is written when change returns an error*/
+func (store *Store) update(change func(contents *storeContents) error) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.path == "" {
+		contents := store.contents.clone()
+		if err := change(&contents); err != nil {
+			return err
+		}
+		store.contents = contents
+		return nil
+	}
+	return filelock.Locked(store.path, true, func() error {
+		contents, err := store.load()
+		if err != nil {
+			return err
+		}
+		if err := change(&contents); err != nil {
+			return err
+		}
+		return store.save(contents)
+	})
+}
+
+/*load - read the file, a missing or empty file is an empty store*/
+func (store *Store) load() (storeContents, error) {
+	contents := newContents()
+	data, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return contents, nil
+	}
+	if err != nil {
+		return contents, errors.Wrap(err, "error reading recurring expenses")
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &contents); err != nil {
+			return contents, errors.Wrap(err, "error parsing recurring expenses")
+		}
+	}
+	if contents.Runs == nil {
+		contents.Runs = make(map[string]Run)
+	}
+	if contents.Owners == nil {
+		contents.Owners = make(map[string]Credentials)
+	}
+	return contents, nil
+}
+
+/*save - write everything through a temp file of this write alone so readers never see a partial file*/
+func (store *Store) save(contents storeContents) error {
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return errors.Wrap(err, "error encoding recurring expenses")
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "error writing recurring expenses")
+	}
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), store.path)
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return errors.Wrap(err, "error writing recurring expenses")
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package recurring
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*sharedStores - two stores on the same file, like two replicas*/
+func sharedStores(t *testing.T) (*Store, *Store) {
+	path := filepath.Join(t.TempDir(), "recurring.json")
+	first, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return first, second
+}
+
+func TestStoresShareTheFile(t *testing.T) {
+	first, second := sharedStores(t)
+
+	if _, err := first.AddTemplate(Template{Owner: "alice", Name: "Rent"}, Credentials{Token: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.AddTemplate(Template{Owner: "bob", Name: "Internet"}, Credentials{Token: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []*Store{first, second} {
+		templates, err := store.Templates("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(templates) != 2 || templates[0].ID == templates[1].ID {
+			t.Errorf("templates = %+v, want both with their own id", templates)
+		}
+	}
+	if deleted, err := second.DeleteTemplate("alice", 1); err != nil || !deleted {
+		t.Errorf("template of the other store not deleted: %v, %v", deleted, err)
+	}
+	if templates, _ := first.Templates("alice"); len(templates) != 0 {
+		t.Errorf("deleted template still listed: %+v", templates)
+	}
+}
+
+func TestClaimRunOnce(t *testing.T) {
+	first, second := sharedStores(t)
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	run := Run{Key: "1:2019-05-01", TemplateID: 1, Owner: "alice", ScheduledFor: now}
+
+	claimed, previous, ok, err := first.ClaimRun(run, now, 3, time.Minute)
+	if err != nil || !ok || claimed.Status != RunPending || claimed.Attempts != 1 || previous.Key != "" {
+		t.Fatalf("first claim = %+v, %+v, %v, %v", claimed, previous, ok, err)
+	}
+	if _, _, ok, err := second.ClaimRun(run, now.Add(30*time.Second), 3, time.Minute); err != nil || ok {
+		t.Errorf("run claimed again while pending: %v, %v", ok, err)
+	}
+
+	//the claim of a crashed replica expires
+	claimed, previous, ok, err = second.ClaimRun(run, now.Add(time.Minute), 3, time.Minute)
+	if err != nil || !ok || claimed.Attempts != 2 || previous.Status != RunPending {
+		t.Errorf("expired claim = %+v, %+v, %v, %v", claimed, previous, ok, err)
+	}
+
+	claimed.Status = RunSucceeded
+	if err := second.SaveRun(claimed); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok, _ := first.ClaimRun(run, now.Add(time.Hour), 3, time.Minute); ok {
+		t.Error("succeeded run claimed again")
+	}
+}
+
+func TestClaimRunGivesUp(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	run := Run{Key: "1:2019-05-01", TemplateID: 1, Owner: "alice", ScheduledFor: now}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		claimed, _, ok, err := store.ClaimRun(run, now, 3, 0)
+		if err != nil || !ok || claimed.Attempts != attempt {
+			t.Fatalf("attempt %d = %+v, %v, %v", attempt, claimed, ok, err)
+		}
+		claimed.Status = RunFailed
+		store.SaveRun(claimed)
+	}
+	if _, _, ok, _ := store.ClaimRun(run, now, 3, 0); ok {
+		t.Error("run claimed after the last attempt")
+	}
+}
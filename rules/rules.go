@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+/*Rule - category to use for expenses matching all of the set conditions*/
+type Rule struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	//Keywords - description contains any of them, case insensitive
+	Keywords []string `json:"keywords,omitempty"`
+	//Pattern - regular expression matched against the description
+	Pattern string `json:"pattern,omitempty"`
+	//MinAmount, MaxAmount - inclusive bounds on the cost
+	MinAmount string `json:"min_amount,omitempty"`
+	MaxAmount string `json:"max_amount,omitempty"`
+	//PayerID - user who paid part of the expense
+	PayerID    int `json:"payer_id,omitempty"`
+	CategoryID int `json:"category_id"`
+
+	//pattern - Pattern compiled by Validate
+	pattern *regexp.Regexp
+}
+
+/*Subject - the parts of an expense rules look at*/
+type Subject struct {
+	Description string
+	Cost        expense.Money
+	Payers      []int
+}
+
+/*SubjectOfExpense - subject of an expense stored in splitwise*/
+func SubjectOfExpense(individualExpense expense.Expense) (Subject, error) {
+	cost, err := expense.ParseMoney(individualExpense.Cost, individualExpense.CurrencyCode)
+	if err != nil {
+		return Subject{}, err
+	}
+	subject := Subject{Description: individualExpense.Description, Cost: cost}
+	for _, userInfo := range individualExpense.Users {
+		paid, err := userInfo.Paid(individualExpense.CurrencyCode)
+		if err != nil {
+			return Subject{}, err
+		}
+		if paid.Minor > 0 {
+			subject.Payers = append(subject.Payers, userInfo.UserID)
+		}
+	}
+	return subject, nil
+}
+
+/*SubjectOfRequest - subject of an expense about to be created, invalid amounts are left zero*/
+func SubjectOfRequest(request *expense.CreateExpenseRequest) Subject {
+	cost, _ := expense.ParseMoney(request.Cost, request.CurrencyCode)
+	subject := Subject{Description: request.Description, Cost: cost}
+	for _, share := range request.Users {
+		paid, err := expense.ParseMoney(share.PaidShare, request.CurrencyCode)
+		if err == nil && paid.Minor > 0 {
+			subject.Payers = append(subject.Payers, share.UserID)
+		}
+	}
+	return subject
+}
+
+/*Validate - check the rule can be matched and compile its pattern, categories are checked by the caller. A rule with a pattern matches nothing until it is validated*/
+func (rule *Rule) Validate() error {
+	if rule.CategoryID == 0 {
+		return errors.New("category_id is required")
+	}
+	if len(rule.Keywords) == 0 && rule.Pattern == "" && rule.MinAmount == "" && rule.MaxAmount == "" && rule.PayerID == 0 {
+		return errors.New("at least one condition is required")
+	}
+	rule.pattern = nil
+	if rule.Pattern != "" {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return errors.Wrap(err, "invalid pattern")
+		}
+		rule.pattern = pattern
+	}
+	minAmount, ok := parseBound(rule.MinAmount)
+	if !ok {
+		return errors.Errorf("invalid min_amount %q", rule.MinAmount)
+	}
+	maxAmount, ok := parseBound(rule.MaxAmount)
+	if !ok {
+		return errors.Errorf("invalid max_amount %q", rule.MaxAmount)
+	}
+	if minAmount != nil && maxAmount != nil && minAmount.Cmp(maxAmount) > 0 {
+		return errors.New("min_amount is greater than max_amount")
+	}
+	return nil
+}
+
+/*Matches - whether subject meets every condition of the rule*/
+func (rule *Rule) Matches(subject Subject) bool {
+	if len(rule.Keywords) > 0 && !containsKeyword(subject.Description, rule.Keywords) {
+		return false
+	}
+	if rule.Pattern != "" && (rule.pattern == nil || !rule.pattern.MatchString(subject.Description)) {
+		return false
+	}
+
+	cost := new(big.Rat).SetFrac64(subject.Cost.Minor, 1)
+	cost.Quo(cost, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(expense.MinorUnitDigits(subject.Cost.Currency))), nil)))
+	if minAmount, ok := parseBound(rule.MinAmount); !ok || (minAmount != nil && cost.Cmp(minAmount) < 0) {
+		return false
+	}
+	if maxAmount, ok := parseBound(rule.MaxAmount); !ok || (maxAmount != nil && cost.Cmp(maxAmount) > 0) {
+		return false
+	}
+
+	if rule.PayerID != 0 {
+		for _, payer := range subject.Payers {
+			if payer == rule.PayerID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+/*Suggest - first rule in order matching subject*/
+func Suggest(ruleList []Rule, subject Subject) (Rule, bool) {
+	for _, rule := range ruleList {
+		if rule.Matches(subject) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func containsKeyword(description string, keywords []string) bool {
+	description = strings.ToLower(description)
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(description, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+/*parseBound - optional decimal amount, nil when empty*/
+func parseBound(amount string) (*big.Rat, bool) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return nil, true
+	}
+	bound, ok := new(big.Rat).SetString(amount)
+	return bound, ok
+}
@@ -0,0 +1,38 @@
+package rules
+
+import (
+	"path/filepath"
+	"testing"
+
+	"splitwiseAngularAPI/expense"
+)
+
+func TestPatternCompiledOnValidateAndLoad(t *testing.T) {
+	subject := Subject{Description: "Netflix May", Cost: expense.Money{Minor: 1599, Currency: "USD"}}
+	rule := Rule{Pattern: `^netflix|^Netflix`, CategoryID: 5}
+	if rule.Matches(subject) {
+		t.Error("rule matched before its pattern was compiled")
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !rule.Matches(subject) {
+		t.Error("validated rule did not match")
+	}
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add("alice", rule); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Suggest(reloaded.List("alice"), subject); !ok {
+		t.Error("rule loaded from the file did not match")
+	}
+}
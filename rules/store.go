@@ -0,0 +1,119 @@
+package rules
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+/*Store - rules of every user in priority order, kept in memory and written to path when it is set*/
+type Store struct {
+	mutex sync.Mutex
+	path  string
+	rules map[string][]Rule
+}
+
+/*NewMemoryStore - store which loses its rules on restart*/
+func NewMemoryStore() *Store {
+	return &Store{rules: make(map[string][]Rule)}
+}
+
+/*NewStore - store backed by the json file at path, in memory only when path is empty*/
+func NewStore(path string) (*Store, error) {
+	store := NewMemoryStore()
+	if path == "" {
+		return store, nil
+	}
+	store.path = path
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading rules")
+	}
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, &store.rules); err != nil {
+			return nil, errors.Wrap(err, "error parsing rules")
+		}
+	}
+
+	//compile the patterns once
+	for user, userRules := range store.rules {
+		for index := range userRules {
+			if err := userRules[index].Validate(); err != nil {
+				return nil, errors.Wrapf(err, "error parsing rule %d of user %s", userRules[index].ID, user)
+			}
+		}
+	}
+	return store, nil
+}
+
+/*List - rules of user in priority order*/
+func (store *Store) List(user string) []Rule {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return append([]Rule{}, store.rules[user]...)
+}
+
+/*Add - append rule with a new id as the lowest priority rule of user*/
+func (store *Store) Add(user string, rule Rule) (Rule, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	rule.ID = 1
+	for _, existing := range store.rules[user] {
+		if existing.ID >= rule.ID {
+			rule.ID = existing.ID + 1
+		}
+	}
+	store.rules[user] = append(store.rules[user], rule)
+	if err := store.save(); err != nil {
+		store.rules[user] = store.rules[user][:len(store.rules[user])-1]
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+/*Delete - remove rule id of user, false when there is no such rule*/
+func (store *Store) Delete(user string, id int) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	userRules := store.rules[user]
+	for index, rule := range userRules {
+		if rule.ID != id {
+			continue
+		}
+		remaining := append(append([]Rule(nil), userRules[:index]...), userRules[index+1:]...)
+		store.rules[user] = remaining
+		if err := store.save(); err != nil {
+			store.rules[user] = userRules
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+/*save - write all rules through a temp file so readers never see a partial file*/
+func (store *Store) save() error {
+	if store.path == "" {
+		return nil
+	}
+	contents, err := json.Marshal(store.rules)
+	if err != nil {
+		return errors.Wrap(err, "error encoding rules")
+	}
+
+	tempPath := store.path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, contents, 0600); err != nil {
+		return errors.Wrap(err, "error writing rules")
+	}
+	return errors.Wrap(os.Rename(tempPath, store.path), "error writing rules")
+}
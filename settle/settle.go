@@ -0,0 +1,205 @@
+package settle
+
+import (
+	"sort"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+//maxExactMembers - above this many unsettled members per currency the plan is
+//computed greedily instead of searching for the minimal number of transfers
+const maxExactMembers = 16
+
+/*Plan - minimal transfers per currency settling what each user paid against what they owe across the expenses, deleted expenses are ignored*/
+func Plan(expenses []expense.Expense) ([]expense.Debt, error) {
+	balances, err := Balances(expenses)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make([]string, 0, len(balances))
+	for currency := range balances {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	transfers := make([]expense.Debt, 0)
+	for _, currency := range currencies {
+		minorBalances := make(map[int]int64)
+		for userID, balance := range balances[currency] {
+			minorBalances[userID] = balance.Minor
+		}
+		for _, transfer := range settleCurrency(minorBalances) {
+			transfers = append(transfers, expense.Debt{
+				From:         transfer.from,
+				To:           transfer.to,
+				Amount:       expense.Money{Minor: transfer.cents, Currency: currency}.String(),
+				CurrencyCode: currency,
+			})
+		}
+	}
+	return transfers, nil
+}
+
+/*Balances - net amount per currency and user, positive when the user is owed*/
+func Balances(expenses []expense.Expense) (map[string]map[int]expense.Money, error) {
+	balances := make(map[string]map[int]expense.Money)
+	for _, individualExpense := range expenses {
+		if !individualExpense.DeletedAt.IsZero() {
+			continue
+		}
+
+		currency := individualExpense.CurrencyCode
+		if balances[currency] == nil {
+			balances[currency] = make(map[int]expense.Money)
+		}
+		for _, userInfo := range individualExpense.Users {
+			paid, err := userInfo.Paid(currency)
+			if err != nil {
+				return nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+			}
+			owed, err := userInfo.Owed(currency)
+			if err != nil {
+				return nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+			}
+			net, _ := paid.Add(owed.Neg())
+			balances[currency][userInfo.UserID], _ = balances[currency][userInfo.UserID].Add(net)
+		}
+	}
+	return balances, nil
+}
+
+/*FilterByDate - expenses dated in [start, end)*/
+func FilterByDate(expenses []expense.Expense, start time.Time, end time.Time) []expense.Expense {
+	filtered := make([]expense.Expense, 0, len(expenses))
+	for _, individualExpense := range expenses {
+		if individualExpense.Date.Before(start) || !individualExpense.Date.Before(end) {
+			continue
+		}
+		filtered = append(filtered, individualExpense)
+	}
+	return filtered
+}
+
+type transfer struct {
+	from  int
+	to    int
+	cents int64
+}
+
+type balance struct {
+	userID int
+	cents  int64
+}
+
+/*settleCurrency - transfers for the balances of a single currency*/
+func settleCurrency(userBalances map[int]int64) []transfer {
+	unsettled := make([]balance, 0, len(userBalances))
+	for userID, cents := range userBalances {
+		if cents != 0 {
+			unsettled = append(unsettled, balance{userID: userID, cents: cents})
+		}
+	}
+	sort.Slice(unsettled, func(i, j int) bool { return unsettled[i].userID < unsettled[j].userID })
+
+	if len(unsettled) > maxExactMembers {
+		return settleGreedy(unsettled)
+	}
+
+	//n members always settle with n-1 transfers, every subset summing to zero
+	//that can be settled on its own saves one, so find the most such subsets
+	transfers := make([]transfer, 0)
+	for _, subset := range zeroSumSubsets(unsettled) {
+		transfers = append(transfers, settleGreedy(subset)...)
+	}
+	return transfers
+}
+
+/*zeroSumSubsets - partition balances into the largest number of subsets summing to zero*/
+func zeroSumSubsets(balances []balance) [][]balance {
+	count := len(balances)
+	full := 1<<uint(count) - 1
+
+	sums := make([]int64, full+1)
+	for mask := 1; mask <= full; mask++ {
+		lowest := 0
+		for mask&(1<<uint(lowest)) == 0 {
+			lowest++
+		}
+		sums[mask] = sums[mask&^(1<<uint(lowest))] + balances[lowest].cents
+	}
+
+	//best[mask] - most zero sum prefixes of some ordering of mask
+	best := make([]int, full+1)
+	last := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		best[mask] = -1
+		for index := 0; index < count; index++ {
+			if mask&(1<<uint(index)) == 0 {
+				continue
+			}
+			if candidate := best[mask&^(1<<uint(index))]; candidate > best[mask] {
+				best[mask] = candidate
+				last[mask] = index
+			}
+		}
+		if sums[mask] == 0 {
+			best[mask]++
+		}
+	}
+
+	//walk the best ordering backwards, cutting whenever the remaining prefix sums to zero
+	subsets := make([][]balance, 0, best[full])
+	current := make([]balance, 0)
+	for mask := full; mask != 0; {
+		index := last[mask]
+		current = append(current, balances[index])
+		mask &^= 1 << uint(index)
+		if sums[mask] == 0 {
+			subsets = append(subsets, current)
+			current = make([]balance, 0)
+		}
+	}
+	return subsets
+}
+
+/*settleGreedy - repeatedly let the largest debtor pay the largest creditor, at most n-1 transfers for n balances summing to zero*/
+func settleGreedy(balances []balance) []transfer {
+	debtors := make([]balance, 0)
+	creditors := make([]balance, 0)
+	for _, individual := range balances {
+		if individual.cents < 0 {
+			debtors = append(debtors, balance{userID: individual.userID, cents: -individual.cents})
+		} else if individual.cents > 0 {
+			creditors = append(creditors, individual)
+		}
+	}
+	byAmount := func(balances []balance) {
+		sort.SliceStable(balances, func(i, j int) bool { return balances[i].cents > balances[j].cents })
+	}
+
+	transfers := make([]transfer, 0)
+	for len(debtors) > 0 && len(creditors) > 0 {
+		byAmount(debtors)
+		byAmount(creditors)
+
+		cents := debtors[0].cents
+		if creditors[0].cents < cents {
+			cents = creditors[0].cents
+		}
+		transfers = append(transfers, transfer{from: debtors[0].userID, to: creditors[0].userID, cents: cents})
+
+		debtors[0].cents -= cents
+		creditors[0].cents -= cents
+		if debtors[0].cents == 0 {
+			debtors = debtors[1:]
+		}
+		if creditors[0].cents == 0 {
+			creditors = creditors[1:]
+		}
+	}
+	return transfers
+}
@@ -0,0 +1,118 @@
+package settle
+
+import (
+	"testing"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+)
+
+/*settles - whether transfers of positive amounts bring every balance to zero*/
+func settles(balances map[int]int64, transfers []transfer) bool {
+	remaining := make(map[int]int64)
+	for userID, cents := range balances {
+		remaining[userID] = cents
+	}
+	for _, transfer := range transfers {
+		if transfer.cents <= 0 || transfer.from == transfer.to {
+			return false
+		}
+		remaining[transfer.from] += transfer.cents
+		remaining[transfer.to] -= transfer.cents
+	}
+	for _, cents := range remaining {
+		if cents != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSettleCurrency(t *testing.T) {
+	tests := []struct {
+		name      string
+		balances  map[int]int64
+		transfers int
+	}{
+		{"all settled", map[int]int64{1: 0, 2: 0, 3: 0}, 0},
+		{"no balances", map[int]int64{}, 0},
+		//1 owes 2 who owes 3 the same, 2 is left out
+		{"chain", map[int]int64{1: -1000, 2: 0, 3: 1000}, 1},
+		{"longer chain", map[int]int64{1: -500, 2: -300, 3: 200, 4: 600}, 3},
+		{"two independent pairs", map[int]int64{1: -600, 2: 600, 3: -400, 4: 400}, 2},
+		//largest first would need 4 transfers
+		{"pair and triple", map[int]int64{1: -500, 2: 500, 3: -400, 4: -200, 5: 600}, 3},
+		{"one creditor", map[int]int64{1: -100, 2: -200, 3: -300, 4: 600}, 3},
+	}
+	for _, test := range tests {
+		transfers := settleCurrency(test.balances)
+		if len(transfers) != test.transfers {
+			t.Errorf("%s: %d transfers %+v, want %d", test.name, len(transfers), transfers, test.transfers)
+		}
+		if !settles(test.balances, transfers) {
+			t.Errorf("%s: transfers %+v do not settle %v", test.name, transfers, test.balances)
+		}
+	}
+}
+
+func TestSettleCurrencyGreedyAboveMaxExactMembers(t *testing.T) {
+	//pairs of distinct amounts, largest first pairs them up without searching
+	balances := make(map[int]int64)
+	pairs := maxExactMembers/2 + 1
+	for pair := 1; pair <= pairs; pair++ {
+		balances[2*pair] = int64(-100 * pair)
+		balances[2*pair+1] = int64(100 * pair)
+	}
+	transfers := settleCurrency(balances)
+	if len(transfers) != pairs {
+		t.Errorf("%d transfers for %d pairs", len(transfers), pairs)
+	}
+	if !settles(balances, transfers) {
+		t.Errorf("transfers %+v do not settle %v", transfers, balances)
+	}
+
+	//without pairs greedy still needs at most one transfer less than members
+	balances = map[int]int64{1: -int64(maxExactMembers) * 100}
+	for userID := 2; userID <= maxExactMembers+1; userID++ {
+		balances[userID] = 100
+	}
+	balances[maxExactMembers+2] = -7
+	balances[maxExactMembers+3] = 7
+	transfers = settleCurrency(balances)
+	if len(transfers) > len(balances)-1 || !settles(balances, transfers) {
+		t.Errorf("%d transfers %+v for %d members", len(transfers), transfers, len(balances))
+	}
+}
+
+func TestPlanSkipsDeletedAndSeparatesCurrencies(t *testing.T) {
+	expenses := []expense.Expense{
+		{ID: 1, CurrencyCode: "USD", Users: []expense.UserInfo{
+			{UserID: 1, PaidShare: "30.00", OwedShare: "15.00"},
+			{UserID: 2, OwedShare: "15.00"},
+		}},
+		{ID: 2, CurrencyCode: "JPY", Users: []expense.UserInfo{
+			{UserID: 2, PaidShare: "1000", OwedShare: "500"},
+			{UserID: 1, OwedShare: "500"},
+		}},
+		{ID: 3, CurrencyCode: "USD", DeletedAt: time.Date(2019, 5, 2, 0, 0, 0, 0, time.UTC), Users: []expense.UserInfo{
+			{UserID: 2, PaidShare: "99.00"},
+			{UserID: 1, OwedShare: "99.00"},
+		}},
+	}
+	plan, err := Plan(expenses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []expense.Debt{
+		{From: 1, To: 2, Amount: "500", CurrencyCode: "JPY"},
+		{From: 2, To: 1, Amount: "15.00", CurrencyCode: "USD"},
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("plan %+v, want %+v", plan, want)
+	}
+	for index := range want {
+		if plan[index] != want[index] {
+			t.Errorf("transfer %d = %+v, want %+v", index, plan[index], want[index])
+		}
+	}
+}
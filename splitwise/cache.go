@@ -0,0 +1,46 @@
+package splitwise
+
+import "splitwiseAngularAPI/expense"
+
+/*Cache - response cache the client keeps groups and categories in*/
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	DeletePrefix(prefix string)
+}
+
+/*SetCache - cache groups and categories of user, expense changes drop the user's cached groups since they hold balances*/
+func (client *Client) SetCache(user string, groups Cache, categories Cache) {
+	client.cacheUser = user
+	client.groupCache = groups
+	client.categoryCache = categories
+}
+
+/*cached - value stored under the user's key, callers must not modify it*/
+func (client *Client) cached(cache Cache, key string) (interface{}, bool) {
+	if cache == nil || client.cacheUser == "" {
+		return nil, false
+	}
+	return cache.Get(client.cacheUser + ":" + key)
+}
+
+func (client *Client) store(cache Cache, key string, value interface{}) {
+	if cache == nil || client.cacheUser == "" {
+		return
+	}
+	cache.Set(client.cacheUser+":"+key, value)
+}
+
+/*RefreshGroups - groups of the current user from splitwise even when they are cached, the cache is refilled with them*/
+func (client *Client) RefreshGroups() ([]expense.Group, error) {
+	client.invalidateGroups()
+	return client.GetGroups()
+}
+
+/*invalidateGroups - forget the user's groups after a change to their expenses*/
+func (client *Client) invalidateGroups() {
+	if client.groupCache == nil || client.cacheUser == "" {
+		return
+	}
+	client.groupCache.DeletePrefix(client.cacheUser + ":")
+}
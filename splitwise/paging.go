@@ -0,0 +1,74 @@
+package splitwise
+
+import (
+	"sync"
+
+	"splitwiseAngularAPI/expense"
+)
+
+//DefaultPageSize - expenses per get_expenses request when fetching a whole history
+const DefaultPageSize = 200
+
+//DefaultPageWorkers - get_expenses requests in flight at once when fetching a whole history
+const DefaultPageWorkers = 4
+
+/*SetPaging - page size and number of concurrent requests used when a query has no Limit*/
+func (client *Client) SetPaging(pageSize int, workers int) {
+	if pageSize > 0 {
+		client.pageSize = pageSize
+	}
+	if workers > 0 {
+		client.workers = workers
+	}
+}
+
+/*getAllExpenses - every expense matching query, fetched in pages of pageSize, up to workers pages at a time*/
+func (client *Client) getAllExpenses(query ExpensesQuery) ([]expense.Expense, error) {
+	query.Limit = client.pageSize
+	expenses, err := client.getExpensePage(query)
+	if err != nil || len(expenses) < client.pageSize {
+		return expenses, err
+	}
+
+	nextOffset := query.Offset + client.pageSize
+	for {
+		pages := make([][]expense.Expense, client.workers)
+		pageErrors := make([]error, client.workers)
+		var waitGroup sync.WaitGroup
+		for index := range pages {
+			waitGroup.Add(1)
+			go func(index int) {
+				defer waitGroup.Done()
+				pageQuery := query
+				pageQuery.Offset = nextOffset + index*client.pageSize
+				pages[index], pageErrors[index] = client.getExpensePage(pageQuery)
+			}(index)
+		}
+		waitGroup.Wait()
+
+		for index, page := range pages {
+			if pageErrors[index] != nil {
+				return nil, pageErrors[index]
+			}
+			expenses = append(expenses, page...)
+			if len(page) < client.pageSize {
+				return uniqueExpenses(expenses), nil
+			}
+		}
+		nextOffset += client.workers * client.pageSize
+	}
+}
+
+/*uniqueExpenses - drop repeats, an expense added while paging shifts later pages by one*/
+func uniqueExpenses(expenses []expense.Expense) []expense.Expense {
+	seen := make(map[int]bool, len(expenses))
+	unique := expenses[:0]
+	for _, individualExpense := range expenses {
+		if seen[individualExpense.ID] {
+			continue
+		}
+		seen[individualExpense.ID] = true
+		unique = append(unique, individualExpense)
+	}
+	return unique
+}
@@ -0,0 +1,347 @@
+package splitwise
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+//DefaultBaseURL - splitwise v3.0 api
+const DefaultBaseURL = "https://secure.splitwise.com/api/v3.0"
+
+/*Client - typed client for the splitwise api*/
+type Client struct {
+	httpClient *http.Client
+	//ctx - context of every request, see WithContext
+	ctx      context.Context
+	baseURL  string
+	pageSize int
+	workers  int
+	//cacheUser, groupCache, categoryCache - see SetCache
+	cacheUser     string
+	groupCache    Cache
+	categoryCache Cache
+}
+
+/*NewClient - httpClient must already authorize requests (e.g. an oauth1 client)*/
+func NewClient(httpClient *http.Client, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		httpClient: httpClient,
+		ctx:        context.Background(),
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		pageSize:   DefaultPageSize,
+		workers:    DefaultPageWorkers,
+	}
+}
+
+/*WithContext - copy of the client whose requests are cancelled with ctx, e.g. when the api request they serve ends*/
+func (client *Client) WithContext(ctx context.Context) *Client {
+	copied := *client
+	copied.ctx = ctx
+	return &copied
+}
+
+/*Error - splitwise answered with a failure status or reported errors in the body*/
+type Error struct {
+	StatusCode int
+	Message    string
+	//RetryAfter - how long splitwise asked to wait before retrying, set on rate limits and outages
+	RetryAfter time.Duration
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("splitwise: %d %s", err.StatusCode, err.Message)
+}
+
+/*ExpensesQuery - filters for get_expenses, zero values are not sent. Without a Limit GetExpenses pages through everything*/
+type ExpensesQuery struct {
+	GroupID     int
+	DatedAfter  time.Time
+	DatedBefore time.Time
+	//UpdatedAfter - only expenses changed or deleted since, for incremental syncs
+	UpdatedAfter time.Time
+	Limit        int
+	Offset       int
+}
+
+func (query ExpensesQuery) values() url.Values {
+	values := url.Values{}
+	if query.GroupID != 0 {
+		values.Set("group_id", strconv.Itoa(query.GroupID))
+	}
+	if !query.DatedAfter.IsZero() {
+		values.Set("dated_after", query.DatedAfter.UTC().Format(time.RFC3339))
+	}
+	if !query.DatedBefore.IsZero() {
+		values.Set("dated_before", query.DatedBefore.UTC().Format(time.RFC3339))
+	}
+	if !query.UpdatedAfter.IsZero() {
+		values.Set("updated_after", query.UpdatedAfter.UTC().Format(time.RFC3339))
+	}
+	if query.Limit != 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset != 0 {
+		values.Set("offset", strconv.Itoa(query.Offset))
+	}
+	return values
+}
+
+/*GetCurrentUser - user owning the client's token*/
+func (client *Client) GetCurrentUser() (*expense.User, error) {
+	var userWrapper expense.UserWrapper
+	if err := client.get("get_current_user", nil, &userWrapper); err != nil {
+		return nil, err
+	}
+	return &userWrapper.User, nil
+}
+
+/*GetGroups - groups of the current user*/
+func (client *Client) GetGroups() ([]expense.Group, error) {
+	if groups, ok := client.cached(client.groupCache, "groups"); ok {
+		return groups.([]expense.Group), nil
+	}
+	var groupArrWrapper expense.GroupArrWrapper
+	if err := client.get("get_groups", nil, &groupArrWrapper); err != nil {
+		return nil, err
+	}
+	client.store(client.groupCache, "groups", groupArrWrapper.Groups)
+	return groupArrWrapper.Groups, nil
+}
+
+/*GetGroup - a single group with its members*/
+func (client *Client) GetGroup(groupID int) (*expense.Group, error) {
+	key := "group:" + strconv.Itoa(groupID)
+	if group, ok := client.cached(client.groupCache, key); ok {
+		return group.(*expense.Group), nil
+	}
+	var groupWrapper expense.GroupWrapper
+	query := url.Values{}
+	query.Set("id", strconv.Itoa(groupID))
+	if err := client.get("get_group", query, &groupWrapper); err != nil {
+		return nil, err
+	}
+	client.store(client.groupCache, key, &groupWrapper.Group)
+	return &groupWrapper.Group, nil
+}
+
+/*GetExpenses - expenses matching query newest first, the whole history when query has no Limit*/
+func (client *Client) GetExpenses(query ExpensesQuery) ([]expense.Expense, error) {
+	if query.Limit <= 0 {
+		return client.getAllExpenses(query)
+	}
+	return client.getExpensePage(query)
+}
+
+/*getExpensePage - a single get_expenses request*/
+func (client *Client) getExpensePage(query ExpensesQuery) ([]expense.Expense, error) {
+	var expensesWrapper expense.ExpensesWrapper
+	if err := client.get("get_expenses", query.values(), &expensesWrapper); err != nil {
+		return nil, err
+	}
+	return expensesWrapper.Expenses, nil
+}
+
+/*GetExpense - a single expense, deleted ones included*/
+func (client *Client) GetExpense(expenseID int) (*expense.Expense, error) {
+	var expenseWrapper expense.ExpenseWrapper
+	if err := client.get("get_expense/"+strconv.Itoa(expenseID), nil, &expenseWrapper); err != nil {
+		return nil, err
+	}
+	return &expenseWrapper.Expense, nil
+}
+
+/*GetCategories - splitwise categories with their subcategories*/
+func (client *Client) GetCategories() ([]expense.Categories, error) {
+	if categories, ok := client.cached(client.categoryCache, "categories"); ok {
+		return categories.([]expense.Categories), nil
+	}
+	var categoryWrapper expense.CategoryWrapper
+	if err := client.get("get_categories", nil, &categoryWrapper); err != nil {
+		return nil, err
+	}
+	client.store(client.categoryCache, "categories", categoryWrapper.Categories)
+	return categoryWrapper.Categories, nil
+}
+
+/*CreateExpense - create an expense, newExpense is sent as the json body*/
+func (client *Client) CreateExpense(newExpense interface{}) ([]expense.Expense, error) {
+	//a failed call may still have reached splitwise
+	defer client.invalidateGroups()
+	var expensesWrapper expense.ExpensesWrapper
+	if err := client.post("create_expense", newExpense, &expensesWrapper); err != nil {
+		return nil, err
+	}
+	return expensesWrapper.Expenses, nil
+}
+
+/*UpdateExpense - update an expense, changes is sent as the json body*/
+func (client *Client) UpdateExpense(expenseID int, changes interface{}) ([]expense.Expense, error) {
+	defer client.invalidateGroups()
+	var expensesWrapper expense.ExpensesWrapper
+	if err := client.post("update_expense/"+strconv.Itoa(expenseID), changes, &expensesWrapper); err != nil {
+		return nil, err
+	}
+	return expensesWrapper.Expenses, nil
+}
+
+/*DeleteExpense - soft delete an expense, it can be restored with RestoreExpense*/
+func (client *Client) DeleteExpense(expenseID int) error {
+	defer client.invalidateGroups()
+	return client.postForSuccess("delete_expense/" + strconv.Itoa(expenseID))
+}
+
+/*RestoreExpense - undelete an expense*/
+func (client *Client) RestoreExpense(expenseID int) error {
+	defer client.invalidateGroups()
+	return client.postForSuccess("undelete_expense/" + strconv.Itoa(expenseID))
+}
+
+/*postForSuccess - post without a body to endpoints answering {"success": bool}*/
+func (client *Client) postForSuccess(path string) error {
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := client.post(path, struct{}{}, &result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return &Error{StatusCode: http.StatusBadRequest, Message: path + " was not successful"}
+	}
+	return nil
+}
+
+func (client *Client) get(path string, query url.Values, result interface{}) error {
+	requestURL := client.baseURL + "/" + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(client.ctx, "GET", requestURL, nil)
+	if err != nil {
+		return errors.Wrap(err, "error building splitwise "+path+" request")
+	}
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "error calling splitwise "+path)
+	}
+	return decodeResponse(path, response, result)
+}
+
+func (client *Client) post(path string, body interface{}, result interface{}) error {
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "error encoding splitwise "+path+" request")
+	}
+
+	request, err := http.NewRequestWithContext(client.ctx, "POST", client.baseURL+"/"+path, bytes.NewBuffer(bodyJSON))
+	if err != nil {
+		return errors.Wrap(err, "error building splitwise "+path+" request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "error calling splitwise "+path)
+	}
+	return decodeResponse(path, response, result)
+}
+
+/*errorBody - splitwise reports failures either as "error" or as "errors" (object or array)*/
+type errorBody struct {
+	Error  string          `json:"error"`
+	Errors json.RawMessage `json:"errors"`
+}
+
+func (body errorBody) message() string {
+	messages := make([]string, 0)
+	if body.Error != "" {
+		messages = append(messages, body.Error)
+	}
+
+	//"errors": {"base": ["..."], "cost": ["..."]}
+	var fieldErrors map[string][]string
+	if json.Unmarshal(body.Errors, &fieldErrors) == nil {
+		fields := make([]string, 0, len(fieldErrors))
+		for field := range fieldErrors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			for _, fieldMessage := range fieldErrors[field] {
+				if field == "base" {
+					messages = append(messages, fieldMessage)
+				} else {
+					messages = append(messages, field+": "+fieldMessage)
+				}
+			}
+		}
+	}
+
+	//"errors": ["..."]
+	var listErrors []string
+	if json.Unmarshal(body.Errors, &listErrors) == nil {
+		messages = append(messages, listErrors...)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func decodeResponse(path string, response *http.Response, result interface{}) error {
+	defer response.Body.Close()
+
+	contents, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, "error reading splitwise "+path+" response")
+	}
+
+	//error bodies are not always json, an unparsable body simply carries no message
+	var body errorBody
+	json.Unmarshal(contents, &body)
+	message := body.message()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		if message == "" {
+			message = http.StatusText(response.StatusCode)
+		}
+		return &Error{StatusCode: response.StatusCode, Message: message, RetryAfter: retryAfter(response.Header.Get("Retry-After"))}
+	}
+
+	//splitwise answers some invalid requests with 200 and a list of errors
+	if message != "" {
+		return &Error{StatusCode: http.StatusBadRequest, Message: message}
+	}
+
+	if err := json.Unmarshal(contents, result); err != nil {
+		return errors.Wrap(err, "error parsing splitwise "+path+" response")
+	}
+	return nil
+}
+
+/*retryAfter - Retry-After header given in seconds or as a date, 0 when absent or invalid*/
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && time.Until(date) > 0 {
+		return time.Until(date)
+	}
+	return 0
+}
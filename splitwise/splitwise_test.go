@@ -0,0 +1,60 @@
+package splitwise
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"splitwiseAngularAPI/cache"
+	"splitwiseAngularAPI/expense"
+)
+
+func TestWithContextCancelsRequests(t *testing.T) {
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-hang:
+		}
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client := NewClient(http.DefaultClient, server.URL).WithContext(ctx)
+
+	started := time.Now()
+	if _, err := client.GetGroups(); err == nil {
+		t.Fatal("call outlived its context")
+	}
+	if _, err := client.CreateExpense(map[string]string{}); err == nil {
+		t.Fatal("post outlived its context")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("cancelled calls took %s", elapsed)
+	}
+}
+
+func TestRefreshGroupsBypassesCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"groups": [{"id": 100}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(http.DefaultClient, server.URL)
+	client.SetCache("1", cache.New(time.Minute, 10), cache.New(time.Minute, 10))
+	for _, get := range []func() ([]expense.Group, error){client.GetGroups, client.GetGroups, client.RefreshGroups, client.GetGroups} {
+		if groups, err := get(); err != nil || len(groups) != 1 {
+			t.Fatalf("groups = %v, %v", groups, err)
+		}
+	}
+	if count := atomic.LoadInt32(&requests); count != 2 {
+		t.Errorf("splitwise was asked %d times, want once for the cache and once for the refresh", count)
+	}
+}
@@ -2,17 +2,16 @@ package main
 
 import (
 	"flag"
-	"log"
 	"net/http"
 	"os"
 	"splitwiseAngularAPI/controller"
+	"strconv"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 )
 
 var router = mux.NewRouter()
-var Trace *log.Logger
 
 func main() {
 
@@ -21,6 +20,9 @@ func main() {
 
 	//read config
 	configFilePathPtr := flag.String("config", "config.json", "config file path - default config.json will be used")
+
+	//every configuration field can also be given as a flag, overriding the file and env vars
+	controller.RegisterConfigFlags(flag.CommandLine)
 	flag.Parse()
 
 	//controller logger
@@ -28,31 +30,97 @@ func main() {
 	controller.InitLogger(traceFile)
 	defer traceFile.Close()
 
-	controller.InitializeConfig(*configFilePathPtr)
+	//the default config file is optional, env vars and flags can configure everything
+	configFilePath := *configFilePathPtr
+	if !flagSet("config") {
+		if _, err := os.Stat(configFilePath); os.IsNotExist(err) {
+			configFilePath = ""
+		}
+	}
+	conf := controller.InitializeConfig(configFilePath, flag.CommandLine)
 
 	//initialize router
 	http.Handle("/", router)
 
 	//add handlers
-	router.HandleFunc("/", controller.IndexHandler)
-	router.HandleFunc("/logout", controller.Logout).Methods("GET")
-	router.HandleFunc("/expenses", controller.CompleteAuth)
-	router.HandleFunc("/getGroups", controller.GetGroups).Methods("GET")
-	router.HandleFunc("/GetGroupData", controller.GetGroupData).Methods("GET")
-	router.HandleFunc("/GetGroupUsers", controller.GetGroupUsers).Methods("GET")
-	router.HandleFunc("/CreateExpense", controller.CreateExpense).Methods("POST", "OPTIONS", "PUT")
-	router.HandleFunc("/GetCategories", controller.GetCategories).Methods("GET")
+	addHandlers(router)
+
+	//listen
+	err := http.ListenAndServe(":"+strconv.Itoa(conf.Port), withCORS(router, conf.AllowedOrigins))
+	if err != nil {
+		controller.Trace.Fatal("ListenAndServe", err)
+	}
+}
+
+/*flagSet - whether name was given on the command line*/
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(given *flag.Flag) {
+		if given.Name == name {
+			set = true
+		}
+	})
+	return set
+}
 
+/*addHandlers - register all api routes on router*/
+func addHandlers(router *mux.Router) {
+	//outermost first, recovery sits inside the access log so a panic is logged with its 500
+	router.Use(controller.RequestID, controller.AccessLog, controller.Recover, controller.Timeout)
+
+	//login
+	router.HandleFunc("/", controller.Handle(controller.IndexHandler))
+	router.HandleFunc("/expenses", controller.Handle(controller.CompleteAuth))
+
+	//everything else needs a session
+	api := router.PathPrefix("/").Subrouter()
+	api.Use(controller.Authenticate)
+	api.HandleFunc("/logout", controller.Handle(controller.Logout)).Methods("GET")
+	api.HandleFunc("/getGroups", controller.Handle(controller.GetGroups)).Methods("GET")
+	api.HandleFunc("/GetGroupData", controller.Handle(controller.GetGroupData)).Methods("GET")
+	api.HandleFunc("/ExportGroupData", controller.Handle(controller.ExportGroupData)).Methods("GET")
+	api.HandleFunc("/GetGroupUsers", controller.Handle(controller.GetGroupUsers)).Methods("GET")
+	api.HandleFunc("/GetGroupBalances", controller.Handle(controller.GetGroupBalances)).Methods("GET")
+	api.HandleFunc("/GetSettlementPlan", controller.Handle(controller.GetSettlementPlan)).Methods("GET")
+	api.HandleFunc("/GetGroupSummary", controller.Handle(controller.GetGroupSummary)).Methods("GET")
+	api.HandleFunc("/CreateExpense", controller.Handle(controller.CreateExpense)).Methods("POST", "OPTIONS", "PUT")
+	api.HandleFunc("/UpdateExpense/{id}", controller.Handle(controller.UpdateExpense)).Methods("POST", "OPTIONS", "PUT")
+	api.HandleFunc("/DeleteExpense/{id}", controller.Handle(controller.DeleteExpense)).Methods("POST", "OPTIONS", "DELETE")
+	api.HandleFunc("/RestoreExpense/{id}", controller.Handle(controller.RestoreExpense)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/ImportPreview", controller.Handle(controller.ImportPreview)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/ImportConfirm", controller.Handle(controller.ImportConfirm)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/GetCategoryRules", controller.Handle(controller.GetCategoryRules)).Methods("GET")
+	api.HandleFunc("/CreateCategoryRule", controller.Handle(controller.CreateCategoryRule)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/DeleteCategoryRule/{id}", controller.Handle(controller.DeleteCategoryRule)).Methods("POST", "OPTIONS", "DELETE")
+	api.HandleFunc("/TestCategoryRules", controller.Handle(controller.TestCategoryRules)).Methods("GET", "POST", "OPTIONS")
+	api.HandleFunc("/GetRecurringExpenses", controller.Handle(controller.GetRecurringExpenses)).Methods("GET")
+	api.HandleFunc("/CreateRecurringExpense", controller.Handle(controller.CreateRecurringExpense)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/DeleteRecurringExpense/{id}", controller.Handle(controller.DeleteRecurringExpense)).Methods("POST", "OPTIONS", "DELETE")
+	api.HandleFunc("/GetRecurringRuns", controller.Handle(controller.GetRecurringRuns)).Methods("GET")
+	api.HandleFunc("/GetBudgets", controller.Handle(controller.GetBudgets)).Methods("GET")
+	api.HandleFunc("/CreateBudget", controller.Handle(controller.CreateBudget)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/UpdateBudget/{id}", controller.Handle(controller.UpdateBudget)).Methods("POST", "OPTIONS", "PUT")
+	api.HandleFunc("/DeleteBudget/{id}", controller.Handle(controller.DeleteBudget)).Methods("POST", "OPTIONS", "DELETE")
+	api.HandleFunc("/GetBudgetStatus", controller.Handle(controller.GetBudgetStatus)).Methods("GET")
+	api.HandleFunc("/GetCategories", controller.Handle(controller.GetCategories)).Methods("GET")
+	api.HandleFunc("/GetCacheStats", controller.Handle(controller.GetCacheStats)).Methods("GET")
+	api.HandleFunc("/GetSyncStatus", controller.Handle(controller.GetSyncStatus)).Methods("GET")
+
+	//unknown routes answer with the same error envelope
+	router.NotFoundHandler = controller.Handle(http.NotFound)
+}
+
+/*withCORS - allow the angular front end to call the api with credentials*/
+func withCORS(handler http.Handler, allowedOrigins []string) http.Handler {
 	//allow headers
-	headers := handlers.AllowedHeaders([]string{"Accept", "X-Requested-With", "Content-Type", "Authorization", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Access-Control-Allow-Credentials", "Access-Control-Allow-Origin"})
+	headers := handlers.AllowedHeaders([]string{"Accept", "X-Requested-With", "Content-Type", "Authorization", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "X-Request-ID", "If-None-Match", "Access-Control-Allow-Credentials", "Access-Control-Allow-Origin"})
 	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
-	origins := handlers.AllowedOrigins([]string{"https://splitwise.atulmirajkar.com", "http://localhost:4200"})
+	origins := handlers.AllowedOrigins(allowedOrigins)
+
+	//let the angular client read the paging cursor, the freshness of mirrored data, error details and cache validators
+	exposed := handlers.ExposedHeaders([]string{"X-Next-Cursor", "X-Data-Source", "X-Mirror-Synced-At", "X-Mirror-Stale", "X-Request-ID", "Retry-After", "ETag"})
 
 	creds := handlers.AllowCredentials()
 
-	//listen
-	err := http.ListenAndServe(":9094", handlers.CORS(headers, methods, origins, creds)(router))
-	if err != nil {
-		Trace.Fatal("ListenAndServe", err)
-	}
+	return handlers.CORS(headers, methods, origins, exposed, creds)(handler)
 }
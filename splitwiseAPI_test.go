@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"splitwiseAngularAPI/controller"
+	"splitwiseAngularAPI/splitwisefake"
+
+	"github.com/gorilla/mux"
+)
+
+//angularHost - AngularHandler of the tests, the client stops at redirects to it
+const angularHost = "angular.invalid"
+
+/*testServer - the real router from addHandlers configured against a fake splitwise*/
+type testServer struct {
+	fake   *splitwisefake.Server
+	api    *httptest.Server
+	client *http.Client
+}
+
+func newTestServer(t *testing.T) *testServer {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { devNull.Close() })
+	controller.InitLogger(devNull)
+
+	fake := splitwisefake.NewServer()
+	t.Cleanup(fake.Close)
+
+	router := mux.NewRouter()
+	addHandlers(router)
+	api := httptest.NewServer(router)
+	t.Cleanup(api.Close)
+
+	err = controller.Configure(&controller.Configuration{
+		RequestTokenURL:  fake.URL + splitwisefake.RequestTokenPath,
+		AuthorizeURL:     fake.URL + splitwisefake.AuthorizePath,
+		AccessTokenURL:   fake.URL + splitwisefake.AccessTokenPath,
+		ConsumerKey:      "key",
+		ConsumerSecret:   "secret",
+		CallbackURL:      api.URL + "/expenses",
+		AngularHandler:   "http://" + angularHost + "/",
+		SplitwiseBaseURL: fake.APIURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(request *http.Request, via []*http.Request) error {
+		if request.URL.Host == angularHost {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}}
+	return &testServer{fake: fake, api: api, client: client}
+}
+
+/*login - follow the oauth dance from / until the redirect back to the angular app*/
+func (server *testServer) login(t *testing.T) {
+	response, err := server.client.Get(server.api.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusFound || !strings.Contains(response.Header.Get("Location"), angularHost) {
+		t.Fatalf("login ended with %d %s, want a redirect to the angular app", response.StatusCode, response.Header.Get("Location"))
+	}
+}
+
+/*do - send a request and decode the json answer, also error envelopes, into result unless it is nil*/
+func (server *testServer) do(t *testing.T, method string, path string, body string, result interface{}) int {
+	request, err := http.NewRequest(method, server.api.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := server.client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	contents, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != nil {
+		if err := json.Unmarshal(contents, result); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, contents)
+		}
+	}
+	return response.StatusCode
+}
+
+/*groupRow - the fields of a GetGroupData row the tests look at*/
+type groupRow struct {
+	ExpenseID   int    `json:"expense_id"`
+	UserID      int    `json:"user_id"`
+	OwedShare   string `json:"owed_share"`
+	Description string `json:"description"`
+}
+
+func expenseIDs(rows []groupRow) map[int]bool {
+	ids := make(map[int]bool)
+	for _, row := range rows {
+		ids[row.ExpenseID] = true
+	}
+	return ids
+}
+
+func TestRequiresLogin(t *testing.T) {
+	server := newTestServer(t)
+	if status := server.do(t, "GET", "/getGroups", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("getGroups without a session answered %d, want 401", status)
+	}
+}
+
+func TestGetGroups(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	var groups []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if status := server.do(t, "GET", "/getGroups", "", &groups); status != http.StatusOK {
+		t.Fatalf("getGroups answered %d", status)
+	}
+	names := make(map[int]string)
+	for _, group := range groups {
+		names[group.ID] = group.Name
+	}
+	if names[splitwisefake.ApartmentGroupID] != "Apartment" || names[splitwisefake.TripGroupID] != "Trip" {
+		t.Errorf("getGroups returned %v, want the Apartment and Trip groups", groups)
+	}
+}
+
+func TestGetGroupData(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	var rows []groupRow
+	status := server.do(t, "GET", "/GetGroupData?groupID=100&from=2019-05-01&to=2019-05-31", "", &rows)
+	if status != http.StatusOK {
+		t.Fatalf("GetGroupData answered %d", status)
+	}
+
+	ids := expenseIDs(rows)
+	for _, expenseID := range []int{1001, 1002, 1005} {
+		if !ids[expenseID] {
+			t.Errorf("expense %d of May is missing", expenseID)
+		}
+	}
+	if ids[1003] {
+		t.Error("deleted expense 1003 is listed")
+	}
+	if ids[1004] {
+		t.Error("expense 1004 of June is listed for May")
+	}
+
+	for _, row := range rows {
+		if row.ExpenseID == 1001 && row.UserID == splitwisefake.BobID && row.OwedShare != "1000.00" {
+			t.Errorf("bob owes %s of the May rent, want 1000.00", row.OwedShare)
+		}
+	}
+}
+
+func TestCreateExpense(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	body := `{"cost": "45.00", "description": "Pizza", "date": "2019-05-20", "group_id": 100, "category_id": 13,
+		"users": [{"user_id": 1, "paid_share": "45.00", "owed_share": "15.00"},
+			{"user_id": 2, "owed_share": "15.00"}, {"user_id": 3, "owed_share": "15.00"}]}`
+	var created struct {
+		Expenses []struct {
+			ID int `json:"id"`
+		} `json:"expenses"`
+	}
+	if status := server.do(t, "POST", "/CreateExpense", body, &created); status != http.StatusOK {
+		t.Fatalf("CreateExpense answered %d", status)
+	}
+	if len(created.Expenses) != 1 {
+		t.Fatalf("CreateExpense returned %d expenses, want 1", len(created.Expenses))
+	}
+
+	stored := false
+	for _, individualExpense := range server.fake.Expenses() {
+		if individualExpense.ID == created.Expenses[0].ID && individualExpense.Description == "Pizza" && individualExpense.Cost == "45.00" {
+			stored = true
+		}
+	}
+	if !stored {
+		t.Error("created expense is not stored in splitwise")
+	}
+
+	var rows []groupRow
+	if status := server.do(t, "GET", "/GetGroupData?groupID=100&from=2019-05-01&to=2019-05-31", "", &rows); status != http.StatusOK {
+		t.Fatalf("GetGroupData answered %d", status)
+	}
+	if !expenseIDs(rows)[created.Expenses[0].ID] {
+		t.Error("created expense is not listed by GetGroupData")
+	}
+}
+
+func TestCreateExpenseValidation(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+	before := len(server.fake.Expenses())
+
+	body := `{"cost": "45.00", "description": "Pizza", "group_id": 100,
+		"users": [{"user_id": 1, "paid_share": "45.00", "owed_share": "40.00"}, {"user_id": 2, "owed_share": "1.00"}]}`
+	if status := server.do(t, "POST", "/CreateExpense", body, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("CreateExpense with shares not adding up answered %d, want 422", status)
+	}
+	if len(server.fake.Expenses()) != before {
+		t.Error("invalid expense reached splitwise")
+	}
+}
+
+/*fieldErrors - errors of the envelope of a rejected request*/
+type fieldErrors struct {
+	Errors map[string][]string `json:"errors"`
+}
+
+func TestUpdateExpense(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	var updated struct {
+		Expenses []struct {
+			Description string `json:"description"`
+			Cost        string `json:"cost"`
+			Users       []struct {
+				UserID    int    `json:"user_id"`
+				OwedShare string `json:"owed_share"`
+			} `json:"users"`
+		} `json:"expenses"`
+	}
+	if status := server.do(t, "PUT", "/UpdateExpense/1001", `{"description": "Rent May"}`, &updated); status != http.StatusOK {
+		t.Fatalf("renaming answered %d", status)
+	}
+	if len(updated.Expenses) != 1 || updated.Expenses[0].Description != "Rent May" || len(updated.Expenses[0].Users) != 3 {
+		t.Errorf("renamed expense = %+v, want the shares kept", updated.Expenses)
+	}
+
+	body := `{"cost": "3300", "users": [{"user_id": 1, "paid_share": "3300", "owed_share": "1100"},
+		{"user_id": 2, "owed_share": "1100"}, {"user_id": 3, "owed_share": "1100"}]}`
+	if status := server.do(t, "PUT", "/UpdateExpense/1001", body, &updated); status != http.StatusOK {
+		t.Fatalf("changing the cost with the shares answered %d", status)
+	}
+	if updated.Expenses[0].Cost != "3300.00" {
+		t.Errorf("cost after the update = %s, want 3300.00", updated.Expenses[0].Cost)
+	}
+}
+
+func TestUpdateExpenseValidation(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"unknown field", `{"payment": true}`, "payment"},
+		{"cost without shares", `{"cost": "4000"}`, "users"},
+		{"malformed cost", `{"cost": "12.345"}`, "cost"},
+		{"malformed share", `{"users": [{"user_id": 1, "paid_share": "3000", "owed_share": "3000,00"}]}`, "users__0__owed_share"},
+		{"shares not adding up", `{"users": [{"user_id": 1, "paid_share": "3000", "owed_share": "1000"}]}`, "users"},
+		{"stranger", `{"users": [{"user_id": 4, "paid_share": "3000", "owed_share": "3000"}]}`, "users__0__user_id"},
+	}
+	for _, test := range tests {
+		var rejected fieldErrors
+		if status := server.do(t, "PUT", "/UpdateExpense/1001", test.body, &rejected); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: answered %d, want 422", test.name, status)
+			continue
+		}
+		if len(rejected.Errors[test.field]) == 0 {
+			t.Errorf("%s: errors = %v, want one for %s", test.name, rejected.Errors, test.field)
+		}
+	}
+
+	var rent struct {
+		Expenses []struct {
+			Cost string `json:"cost"`
+		} `json:"expenses"`
+	}
+	server.do(t, "PUT", "/UpdateExpense/1001", `{"description": "May rent"}`, &rent)
+	if len(rent.Expenses) != 1 || rent.Expenses[0].Cost != "3000.00" {
+		t.Errorf("rejected updates changed the expense: %+v", rent.Expenses)
+	}
+}
+
+func TestImportPreviewFlagsCredits(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	body := `{"group_id": 100, "currency_code": "USD",
+		"mapping": {"date": "0", "description": "1", "amount": "2", "sign": "debits_negative"},
+		"csv": "2019-06-02,GROCERY STORE,-45.10\n2019-06-03,REFUND GROCERY STORE,12.00\n"}`
+	var preview struct {
+		Rows []struct {
+			Line    int             `json:"line"`
+			Credit  bool            `json:"credit"`
+			Expense json.RawMessage `json:"expense"`
+		} `json:"rows"`
+	}
+	if status := server.do(t, "POST", "/ImportPreview", body, &preview); status != http.StatusOK {
+		t.Fatalf("ImportPreview answered %d", status)
+	}
+	if len(preview.Rows) != 2 {
+		t.Fatalf("preview has %d rows, want 2", len(preview.Rows))
+	}
+	if debit := preview.Rows[0]; debit.Credit || debit.Expense == nil {
+		t.Errorf("purchase on line %d is not suggested as an expense", debit.Line)
+	}
+	if credit := preview.Rows[1]; !credit.Credit || credit.Expense != nil {
+		t.Errorf("refund on line %d is suggested as an expense", credit.Line)
+	}
+}
+
+func TestReportsReadTheMirror(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	dates := "groupID=100&from=2019-05-01&to=2019-05-31"
+	if status := server.do(t, "GET", "/GetGroupData?"+dates, "", nil); status != http.StatusOK {
+		t.Fatalf("GetGroupData answered %d", status)
+	}
+	fetched := server.fake.RequestCount(splitwisefake.APIPath + "/get_expenses")
+
+	for _, path := range []string{"/ExportGroupData", "/GetGroupSummary", "/GetSettlementPlan"} {
+		response, err := server.client.Get(server.api.URL + path + "?" + dates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("%s answered %d", path, response.StatusCode)
+		}
+		if source := response.Header.Get("X-Data-Source"); source != "mirror" {
+			t.Errorf("%s read its expenses from %q, want the mirror", path, source)
+		}
+	}
+	if count := server.fake.RequestCount(splitwisefake.APIPath + "/get_expenses"); count != fetched {
+		t.Errorf("reports fetched expenses from splitwise %d more times", count-fetched)
+	}
+}
+
+func TestOversizedUserIndex(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+	before := len(server.fake.Expenses())
+
+	body := `{"cost": "45.00", "description": "Pizza", "group_id": 100, "users__100000000__user_id": 1}`
+	for _, request := range []struct{ method, path string }{{"POST", "/CreateExpense"}, {"PUT", "/UpdateExpense/1001"}} {
+		var rejected fieldErrors
+		if status := server.do(t, request.method, request.path, body, &rejected); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s answered %d, want 422", request.path, status)
+		}
+		if len(rejected.Errors["users__100000000__user_id"]) == 0 {
+			t.Errorf("%s errors = %v, want one for the index", request.path, rejected.Errors)
+		}
+	}
+	if len(server.fake.Expenses()) != before {
+		t.Error("expense with an oversized user index reached splitwise")
+	}
+}
+
+func TestRevokedTokenIsNotHiddenByTheMirror(t *testing.T) {
+	server := newTestServer(t)
+	server.login(t)
+
+	dates := "groupID=100&from=2019-05-01&to=2019-05-31"
+	if status := server.do(t, "GET", "/GetGroupData?"+dates, "", nil); status != http.StatusOK {
+		t.Fatalf("GetGroupData answered %d", status)
+	}
+	//the groups are cached now, the next sync must still ask splitwise for them
+	if status := server.do(t, "GET", "/getGroups", "", nil); status != http.StatusOK {
+		t.Fatalf("getGroups answered %d", status)
+	}
+	body := `{"cost": "12.00", "description": "Milk", "date": "2019-05-20", "group_id": 100,
+		"users": [{"user_id": 1, "paid_share": "12.00", "owed_share": "6.00"}, {"user_id": 2, "owed_share": "6.00"}]}`
+	if status := server.do(t, "POST", "/CreateExpense", body, nil); status != http.StatusOK {
+		t.Fatalf("CreateExpense answered %d", status)
+	}
+
+	server.fake.Fail(splitwisefake.APIPath+"/get_groups", http.StatusUnauthorized)
+	if status := server.do(t, "GET", "/GetGroupData?"+dates, "", nil); status != http.StatusUnauthorized {
+		t.Errorf("GetGroupData with a revoked token answered %d, want 401", status)
+	}
+}
@@ -0,0 +1,167 @@
+package splitwisefake
+
+import (
+	"time"
+
+	"splitwiseAngularAPI/expense"
+)
+
+//fixture ids, exported so tests can refer to the seeded data
+const (
+	AliceID = 1
+	BobID   = 2
+	CarolID = 3
+
+	ApartmentGroupID = 100
+	TripGroupID      = 200
+
+	UtilitiesCategoryID = 1
+	RentCategoryID      = 3
+	ElectricityID       = 5
+	FoodCategoryID      = 25
+	GroceriesID         = 12
+	DiningOutID         = 13
+)
+
+func seedUsers() map[int]expense.User {
+	return map[int]expense.User{
+		AliceID: {ID: AliceID, FirstName: "Alice", LastName: "Anders", Email: "alice@example.com", DefaultCurrency: "USD", TimeZone: "America/New_York"},
+		BobID:   {ID: BobID, FirstName: "Bob", LastName: "Brown", Email: "bob@example.com", DefaultCurrency: "USD", TimeZone: "America/Chicago"},
+		CarolID: {ID: CarolID, FirstName: "Carol", LastName: "Clark", Email: "carol@example.com", DefaultCurrency: "EUR", TimeZone: "Europe/Berlin"},
+	}
+}
+
+func seedGroups() map[int]*expense.Group {
+	return map[int]*expense.Group{
+		ApartmentGroupID: {
+			ID:   ApartmentGroupID,
+			Name: "Apartment",
+			Members: []expense.Members{
+				{ID: AliceID, FirstName: "Alice", Balance: []expense.Balance{{CurrencyCode: "USD", Amount: "60.0"}}},
+				{ID: BobID, FirstName: "Bob", Balance: []expense.Balance{{CurrencyCode: "USD", Amount: "-30.0"}}},
+				{ID: CarolID, FirstName: "Carol", Balance: []expense.Balance{{CurrencyCode: "USD", Amount: "-30.0"}}},
+			},
+			SimplifyByDefault: true,
+			OriginalDebts: []expense.Debt{
+				{From: BobID, To: AliceID, Amount: "40.0", CurrencyCode: "USD"},
+				{From: CarolID, To: AliceID, Amount: "20.0", CurrencyCode: "USD"},
+				{From: CarolID, To: BobID, Amount: "10.0", CurrencyCode: "USD"},
+			},
+			SimplifiedDebts: []expense.Debt{
+				{From: BobID, To: AliceID, Amount: "30.0", CurrencyCode: "USD"},
+				{From: CarolID, To: AliceID, Amount: "30.0", CurrencyCode: "USD"},
+			},
+		},
+		TripGroupID: {
+			ID:   TripGroupID,
+			Name: "Trip",
+			Members: []expense.Members{
+				{ID: AliceID, FirstName: "Alice"},
+				{ID: BobID, FirstName: "Bob"},
+			},
+		},
+	}
+}
+
+func seedCategories() []expense.Categories {
+	return []expense.Categories{
+		{ID: UtilitiesCategoryID, Name: "Utilities", Subcategories: []expense.Subcategories{
+			{ID: RentCategoryID, Name: "Rent"},
+			{ID: ElectricityID, Name: "Electricity"},
+		}},
+		{ID: FoodCategoryID, Name: "Food and drink", Subcategories: []expense.Subcategories{
+			{ID: GroceriesID, Name: "Groceries"},
+			{ID: DiningOutID, Name: "Dining out"},
+		}},
+	}
+}
+
+func seedExpenses() []expense.Expense {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+	}
+	deleted := date(2019, time.May, 20)
+
+	expenses := []expense.Expense{
+		{
+			ID: 1001, GroupID: ApartmentGroupID, Description: "May rent", CurrencyCode: "USD", Date: date(2019, time.May, 1),
+			Category: expense.Category{ID: RentCategoryID, Name: "Rent"},
+			Users: []expense.UserInfo{
+				{UserID: AliceID, PaidShare: "3000.0", OwedShare: "1000.0"},
+				{UserID: BobID, OwedShare: "1000.0"},
+				{UserID: CarolID, OwedShare: "1000.0"},
+			},
+		},
+		{
+			ID: 1002, GroupID: ApartmentGroupID, Description: "Electricity", CurrencyCode: "USD", Date: date(2019, time.May, 15),
+			Category: expense.Category{ID: ElectricityID, Name: "Electricity"},
+			Users: []expense.UserInfo{
+				{UserID: AliceID, OwedShare: "30.01"},
+				{UserID: BobID, PaidShare: "90.01", OwedShare: "30.0"},
+				{UserID: CarolID, OwedShare: "30.0"},
+			},
+		},
+		{
+			ID: 1003, GroupID: ApartmentGroupID, Description: "Groceries (duplicate)", CurrencyCode: "USD", Date: date(2019, time.May, 16),
+			Category: expense.Category{ID: GroceriesID, Name: "Groceries"},
+			Users: []expense.UserInfo{
+				{UserID: AliceID, PaidShare: "40.0", OwedShare: "20.0"},
+				{UserID: BobID, OwedShare: "20.0"},
+			},
+			DeletedAt: deleted,
+		},
+		{
+			ID: 1004, GroupID: ApartmentGroupID, Description: "June rent", CurrencyCode: "USD", Date: date(2019, time.June, 1),
+			Category: expense.Category{ID: RentCategoryID, Name: "Rent"},
+			Users: []expense.UserInfo{
+				{UserID: AliceID, OwedShare: "1000.0"},
+				{UserID: BobID, PaidShare: "3000.0", OwedShare: "1000.0"},
+				{UserID: CarolID, OwedShare: "1000.0"},
+			},
+		},
+		{
+			ID: 2001, GroupID: TripGroupID, Description: "Dinner", CurrencyCode: "EUR", Date: date(2019, time.May, 10),
+			Category: expense.Category{ID: DiningOutID, Name: "Dining out"},
+			Users: []expense.UserInfo{
+				{UserID: AliceID, PaidShare: "91.0", OwedShare: "45.5"},
+				{UserID: BobID, OwedShare: "45.5"},
+			},
+		},
+		{
+			ID: 1005, GroupID: ApartmentGroupID, Description: "Payment", CurrencyCode: "USD", Payment: true, Date: date(2019, time.May, 25),
+			Category: expense.Category{ID: 18, Name: "General"},
+			Users: []expense.UserInfo{
+				{UserID: AliceID, OwedShare: "100.0"},
+				{UserID: BobID, PaidShare: "100.0"},
+			},
+		},
+	}
+
+	users := seedUsers()
+	for index := range expenses {
+		fillTotals(&expenses[index], users)
+		expenses[index].UpdatedAt = expenses[index].Date
+		if !expenses[index].DeletedAt.IsZero() {
+			expenses[index].UpdatedAt = expenses[index].DeletedAt
+		}
+	}
+	return expenses
+}
+
+/*fillTotals - derive cost, net balances and creator from the shares like splitwise does, the first payer is taken as the creator*/
+func fillTotals(individualExpense *expense.Expense, users map[int]expense.User) {
+	cost := expense.Money{Currency: individualExpense.CurrencyCode}
+	for index, userInfo := range individualExpense.Users {
+		owed, _ := userInfo.Owed(individualExpense.CurrencyCode)
+		paid, _ := userInfo.Paid(individualExpense.CurrencyCode)
+		cost, _ = cost.Add(owed)
+		net, _ := paid.Add(owed.Neg())
+		individualExpense.Users[index].NetBalance = net.String()
+
+		if individualExpense.CreatedBy == nil && !paid.IsZero() {
+			creator := users[userInfo.UserID]
+			individualExpense.CreatedBy = &creator
+		}
+	}
+	individualExpense.Cost = cost.String()
+}
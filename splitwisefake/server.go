@@ -0,0 +1,567 @@
+package splitwisefake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+)
+
+//paths served by the fake, relative to Server.URL
+const (
+	RequestTokenPath = "/oauth/request_token"
+	AuthorizePath    = "/oauth/authorize"
+	AccessTokenPath  = "/oauth/access_token"
+	APIPath          = "/api/v3.0"
+)
+
+/*Server - in memory splitwise implementing the oauth1 dance and the v3.0 endpoints used by the api, seeded with fixture data*/
+type Server struct {
+	*httptest.Server
+
+	mutex      sync.Mutex
+	users      map[int]expense.User
+	groups     map[int]*expense.Group
+	categories []expense.Categories
+	expenses   []expense.Expense
+	nextID     int
+
+	//oauth state
+	loginUserID   int
+	tokenCount    int
+	requestTokens map[string]*requestToken
+	accessTokens  map[string]int
+	requestCounts map[string]int
+	failures      map[string]int
+}
+
+type requestToken struct {
+	callback string
+	verifier string
+	userID   int
+}
+
+/*NewServer - start a fake splitwise with the fixture data, Close it when done*/
+func NewServer() *Server {
+	server := &Server{
+		users:         seedUsers(),
+		groups:        seedGroups(),
+		categories:    seedCategories(),
+		expenses:      seedExpenses(),
+		nextID:        5000,
+		loginUserID:   AliceID,
+		requestTokens: make(map[string]*requestToken),
+		accessTokens:  make(map[string]int),
+		requestCounts: make(map[string]int),
+		failures:      make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(RequestTokenPath, server.handleRequestToken)
+	mux.HandleFunc(AuthorizePath, server.handleAuthorize)
+	mux.HandleFunc(AccessTokenPath, server.handleAccessToken)
+	mux.HandleFunc(APIPath+"/get_current_user", server.authorized(server.getCurrentUser))
+	mux.HandleFunc(APIPath+"/get_groups", server.authorized(server.getGroups))
+	mux.HandleFunc(APIPath+"/get_group", server.authorized(server.getGroup))
+	mux.HandleFunc(APIPath+"/get_expenses", server.authorized(server.getExpenses))
+	mux.HandleFunc(APIPath+"/get_expense/", server.authorized(server.getExpense))
+	mux.HandleFunc(APIPath+"/get_categories", server.authorized(server.getCategories))
+	mux.HandleFunc(APIPath+"/create_expense", server.authorized(server.createExpense))
+	mux.HandleFunc(APIPath+"/update_expense/", server.authorized(server.updateExpense))
+	mux.HandleFunc(APIPath+"/delete_expense/", server.authorized(server.deleteExpense))
+	mux.HandleFunc(APIPath+"/undelete_expense/", server.authorized(server.undeleteExpense))
+
+	server.Server = httptest.NewServer(server.countRequests(mux))
+	return server
+}
+
+/*APIURL - base url to configure the splitwise client with*/
+func (server *Server) APIURL() string {
+	return server.URL + APIPath
+}
+
+/*LoginAs - user who approves the next authorize requests, Alice by default*/
+func (server *Server) LoginAs(userID int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.loginUserID = userID
+}
+
+/*Expenses - copy of all stored expenses including deleted ones*/
+func (server *Server) Expenses() []expense.Expense {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]expense.Expense(nil), server.expenses...)
+}
+
+/*RequestCount - number of requests received for path, e.g. APIPath+"/get_groups"*/
+func (server *Server) RequestCount(path string) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.requestCounts[path]
+}
+
+/*Fail - answer every request for path with status until Fail(path, 0), rate limits and outages carry a Retry-After of 30 seconds*/
+func (server *Server) Fail(path string, status int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if status == 0 {
+		delete(server.failures, path)
+		return
+	}
+	server.failures[path] = status
+}
+
+func (server *Server) countRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.requestCounts[r.URL.Path]++
+		status := server.failures[r.URL.Path]
+		server.mutex.Unlock()
+
+		if status != 0 {
+			if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "30")
+			}
+			writeJSON(w, status, map[string]string{"error": http.StatusText(status)})
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+/********************************************oauth*******************************/
+
+/*oauthParams - parameters of an "Authorization: OAuth k="v", ..." header*/
+func oauthParams(r *http.Request) map[string]string {
+	params := make(map[string]string)
+	header := strings.TrimPrefix(r.Header.Get("Authorization"), "OAuth ")
+	for _, pair := range strings.Split(header, ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+		value, err := url.PathUnescape(strings.Trim(keyValue[1], `"`))
+		if err != nil {
+			continue
+		}
+		params[keyValue[0]] = value
+	}
+	return params
+}
+
+func (server *Server) newToken(prefix string) string {
+	server.tokenCount++
+	return prefix + strconv.Itoa(server.tokenCount)
+}
+
+func (server *Server) handleRequestToken(w http.ResponseWriter, r *http.Request) {
+	params := oauthParams(r)
+
+	server.mutex.Lock()
+	token := server.newToken("request-")
+	server.requestTokens[token] = &requestToken{callback: params["oauth_callback"]}
+	server.mutex.Unlock()
+
+	values := url.Values{}
+	values.Set("oauth_token", token)
+	values.Set("oauth_token_secret", token+"-secret")
+	values.Set("oauth_callback_confirmed", "true")
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+	fmt.Fprint(w, values.Encode())
+}
+
+/*handleAuthorize - approves immediately as the LoginAs user, or as ?user_id=*/
+func (server *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("oauth_token")
+
+	server.mutex.Lock()
+	pending, ok := server.requestTokens[token]
+	if ok {
+		pending.userID = server.loginUserID
+		if userID, err := strconv.Atoi(r.URL.Query().Get("user_id")); err == nil {
+			pending.userID = userID
+		}
+		pending.verifier = server.newToken("verifier-")
+	}
+	server.mutex.Unlock()
+
+	if !ok {
+		http.Error(w, "unknown request token", http.StatusUnauthorized)
+		return
+	}
+
+	callback, err := url.Parse(pending.callback)
+	if err != nil || pending.callback == "" {
+		http.Error(w, "missing callback", http.StatusBadRequest)
+		return
+	}
+	query := callback.Query()
+	query.Set("oauth_token", token)
+	query.Set("oauth_verifier", pending.verifier)
+	callback.RawQuery = query.Encode()
+	http.Redirect(w, r, callback.String(), http.StatusFound)
+}
+
+func (server *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+	params := oauthParams(r)
+
+	server.mutex.Lock()
+	pending, ok := server.requestTokens[params["oauth_token"]]
+	ok = ok && pending.verifier != "" && pending.verifier == params["oauth_verifier"]
+	var token string
+	if ok {
+		delete(server.requestTokens, params["oauth_token"])
+		token = server.newToken("access-")
+		server.accessTokens[token] = pending.userID
+	}
+	server.mutex.Unlock()
+
+	if !ok {
+		http.Error(w, "invalid request token or verifier", http.StatusUnauthorized)
+		return
+	}
+
+	values := url.Values{}
+	values.Set("oauth_token", token)
+	values.Set("oauth_token_secret", token+"-secret")
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+	fmt.Fprint(w, values.Encode())
+}
+
+/********************************************api*******************************/
+
+type apiHandler func(w http.ResponseWriter, r *http.Request, userID int)
+
+/*authorized - resolve the user from the access token like splitwise does*/
+func (server *Server) authorized(handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		userID, ok := server.accessTokens[oauthParams(r)["oauth_token"]]
+		server.mutex.Unlock()
+
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid API request: you are not logged in"})
+			return
+		}
+		handler(w, r, userID)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeErrors(w http.ResponseWriter, messages ...string) {
+	writeJSON(w, http.StatusOK, map[string]map[string][]string{"errors": {"base": messages}})
+}
+
+func (server *Server) isMember(groupID int, userID int) bool {
+	group, ok := server.groups[groupID]
+	if !ok {
+		return false
+	}
+	for _, member := range group.Members {
+		if member.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (server *Server) getCurrentUser(w http.ResponseWriter, r *http.Request, userID int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	writeJSON(w, http.StatusOK, expense.UserWrapper{User: server.users[userID]})
+}
+
+func (server *Server) getGroups(w http.ResponseWriter, r *http.Request, userID int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	groups := make([]expense.Group, 0)
+	for groupID, group := range server.groups {
+		if server.isMember(groupID, userID) {
+			groups = append(groups, *group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	writeJSON(w, http.StatusOK, expense.GroupArrWrapper{Groups: groups})
+}
+
+func (server *Server) getGroup(w http.ResponseWriter, r *http.Request, userID int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	groupID, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	if !server.isMember(groupID, userID) {
+		writeJSON(w, http.StatusNotFound, map[string][]string{"errors": {"Group not found"}})
+		return
+	}
+	writeJSON(w, http.StatusOK, expense.GroupWrapper{Group: *server.groups[groupID]})
+}
+
+func (server *Server) getCategories(w http.ResponseWriter, r *http.Request, userID int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	writeJSON(w, http.StatusOK, expense.CategoryWrapper{Categories: server.categories})
+}
+
+/*parseTime - accepts the formats clients send for dated_after/dated_before*/
+func parseTime(value string) (time.Time, bool) {
+	layouts := []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999 -0700 MST", "2006-01-02"}
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (server *Server) getExpenses(w http.ResponseWriter, r *http.Request, userID int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	query := r.URL.Query()
+	groupID, _ := strconv.Atoi(query.Get("group_id"))
+	datedAfter, hasAfter := parseTime(query.Get("dated_after"))
+	datedBefore, hasBefore := parseTime(query.Get("dated_before"))
+	updatedAfter, hasUpdatedAfter := parseTime(query.Get("updated_after"))
+
+	expenses := make([]expense.Expense, 0)
+	for _, individualExpense := range server.expenses {
+		if !server.isMember(individualExpense.GroupID, userID) {
+			continue
+		}
+		if groupID != 0 && individualExpense.GroupID != groupID {
+			continue
+		}
+		if hasAfter && individualExpense.Date.Before(datedAfter) {
+			continue
+		}
+		if hasBefore && !individualExpense.Date.Before(datedBefore) {
+			continue
+		}
+		if hasUpdatedAfter && !individualExpense.UpdatedAt.After(updatedAfter) {
+			continue
+		}
+		expenses = append(expenses, individualExpense)
+	}
+
+	//newest first like splitwise
+	sort.SliceStable(expenses, func(i, j int) bool { return expenses[i].Date.After(expenses[j].Date) })
+
+	//splitwise defaults to 20 expenses, limit=0 means all
+	limit := 20
+	if value, err := strconv.Atoi(query.Get("limit")); err == nil {
+		limit = value
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	if offset > len(expenses) {
+		offset = len(expenses)
+	}
+	expenses = expenses[offset:]
+	if limit > 0 && limit < len(expenses) {
+		expenses = expenses[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, expense.ExpensesWrapper{Expenses: expenses})
+}
+
+/*stringValue - splitwise accepts numbers and ids either as json strings or numbers*/
+func stringValue(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func (server *Server) createExpense(w http.ResponseWriter, r *http.Request, userID int) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrors(w, "Invalid JSON")
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	newExpense, messages := server.expenseFromBody(body, userID)
+	if len(messages) > 0 {
+		writeErrors(w, messages...)
+		return
+	}
+
+	server.nextID++
+	newExpense.ID = server.nextID
+	server.expenses = append(server.expenses, newExpense)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"expenses": []expense.Expense{newExpense}, "errors": map[string]interface{}{}})
+}
+
+/*expenseFromBody - build an expense from create_expense parameters (users__0__user_id, users__0__owed_share, ...)*/
+func (server *Server) expenseFromBody(body map[string]interface{}, userID int) (expense.Expense, []string) {
+	messages := make([]string, 0)
+	newExpense := expense.Expense{}
+
+	if stringValue(body["description"]) == "" {
+		messages = append(messages, "Description can't be blank")
+	}
+	if cost, err := strconv.ParseFloat(stringValue(body["cost"]), 64); err != nil || cost <= 0 {
+		messages = append(messages, "Cost must be a positive number")
+	}
+
+	newExpense.GroupID, _ = strconv.Atoi(stringValue(body["group_id"]))
+	if newExpense.GroupID != 0 && !server.isMember(newExpense.GroupID, userID) {
+		messages = append(messages, "You are not a member of this group")
+	}
+
+	newExpense.Date = time.Now().UTC()
+	newExpense.CurrencyCode = server.users[userID].DefaultCurrency
+	newExpense.Category = server.category(0)
+	newExpense.Payment = body["payment"] == true
+	creator := server.users[userID]
+	newExpense.CreatedBy = &creator
+	server.applyChanges(&newExpense, body)
+
+	if len(newExpense.Users) == 0 {
+		messages = append(messages, "An expense must involve at least one user")
+	}
+
+	return newExpense, messages
+}
+
+/*applyChanges - copy the optional create/update_expense parameters present in body*/
+func (server *Server) applyChanges(changed *expense.Expense, body map[string]interface{}) {
+	if description := stringValue(body["description"]); description != "" {
+		changed.Description = description
+	}
+	if groupID, err := strconv.Atoi(stringValue(body["group_id"])); err == nil {
+		changed.GroupID = groupID
+	}
+	if currencyCode := stringValue(body["currency_code"]); currencyCode != "" {
+		changed.CurrencyCode = currencyCode
+	}
+	if date, ok := parseTime(stringValue(body["date"])); ok {
+		changed.Date = date
+	}
+	if categoryID, err := strconv.Atoi(stringValue(body["category_id"])); err == nil {
+		changed.Category = server.category(categoryID)
+	}
+
+	users := make([]expense.UserInfo, 0)
+	for index := 0; ; index++ {
+		prefix := "users__" + strconv.Itoa(index) + "__"
+		memberID, err := strconv.Atoi(stringValue(body[prefix+"user_id"]))
+		if err != nil {
+			break
+		}
+		users = append(users, expense.UserInfo{
+			UserID:    memberID,
+			PaidShare: stringValue(body[prefix+"paid_share"]),
+			OwedShare: stringValue(body[prefix+"owed_share"]),
+		})
+	}
+	if len(users) > 0 {
+		changed.Users = users
+	}
+	fillTotals(changed, server.users)
+	changed.UpdatedAt = time.Now().UTC()
+}
+
+/*findExpense - index of the expense with the id at the end of the path, -1 when it does not exist or userID cannot see it*/
+func (server *Server) findExpense(r *http.Request, userID int) int {
+	expenseID, err := strconv.Atoi(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+	if err != nil {
+		return -1
+	}
+	for index, individualExpense := range server.expenses {
+		if individualExpense.ID == expenseID && server.isMember(individualExpense.GroupID, userID) {
+			return index
+		}
+	}
+	return -1
+}
+
+func (server *Server) getExpense(w http.ResponseWriter, r *http.Request, userID int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	index := server.findExpense(r, userID)
+	if index < 0 {
+		writeJSON(w, http.StatusNotFound, map[string][]string{"errors": {"Expense not found"}})
+		return
+	}
+	writeJSON(w, http.StatusOK, expense.ExpenseWrapper{Expense: server.expenses[index]})
+}
+
+func (server *Server) updateExpense(w http.ResponseWriter, r *http.Request, userID int) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrors(w, "Invalid JSON")
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	index := server.findExpense(r, userID)
+	if index < 0 {
+		writeJSON(w, http.StatusNotFound, map[string][]string{"errors": {"Expense not found"}})
+		return
+	}
+
+	server.applyChanges(&server.expenses[index], body)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"expenses": []expense.Expense{server.expenses[index]}, "errors": map[string]interface{}{}})
+}
+
+func (server *Server) deleteExpense(w http.ResponseWriter, r *http.Request, userID int) {
+	server.setDeletedAt(w, r, userID, time.Now().UTC())
+}
+
+func (server *Server) undeleteExpense(w http.ResponseWriter, r *http.Request, userID int) {
+	server.setDeletedAt(w, r, userID, time.Time{})
+}
+
+func (server *Server) setDeletedAt(w http.ResponseWriter, r *http.Request, userID int, deletedAt time.Time) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	index := server.findExpense(r, userID)
+	if index < 0 {
+		writeJSON(w, http.StatusNotFound, map[string][]string{"errors": {"Expense not found"}})
+		return
+	}
+
+	server.expenses[index].DeletedAt = deletedAt
+	server.expenses[index].UpdatedAt = time.Now().UTC()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "errors": map[string]interface{}{}})
+}
+
+/*category - category or subcategory by id, General when unknown*/
+func (server *Server) category(categoryID int) expense.Category {
+	for _, category := range server.categories {
+		if category.ID == categoryID {
+			return expense.Category{ID: category.ID, Name: category.Name}
+		}
+		for _, subcategory := range category.Subcategories {
+			if subcategory.ID == categoryID {
+				return expense.Category{ID: subcategory.ID, Name: subcategory.Name}
+			}
+		}
+	}
+	return expense.Category{ID: 18, Name: "General"}
+}
@@ -0,0 +1,86 @@
+package statement
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"splitwiseAngularAPI/expense"
+)
+
+//duplicateWindow - card transactions often post a day after splitwise's expense date
+const duplicateWindow = 24 * time.Hour
+
+/*FindDuplicate - id of a not deleted, non payment expense with the same cost near date, 0 when there is none*/
+func FindDuplicate(existing []expense.Expense, date time.Time, amount expense.Money) int {
+	for _, individualExpense := range existing {
+		if !individualExpense.DeletedAt.IsZero() || individualExpense.Payment {
+			continue
+		}
+		if individualExpense.CurrencyCode != amount.Currency {
+			continue
+		}
+		cost, err := expense.ParseMoney(individualExpense.Cost, individualExpense.CurrencyCode)
+		if err != nil || cost.Minor != amount.Minor {
+			continue
+		}
+		difference := individualExpense.Date.Sub(date)
+		if difference < 0 {
+			difference = -difference
+		}
+		if difference <= duplicateWindow {
+			return individualExpense.ID
+		}
+	}
+	return 0
+}
+
+/*SuggestCategory - category of the newest earlier expense with a matching description*/
+func SuggestCategory(history []expense.Expense, description string) (expense.Category, bool) {
+	key := normalizeDescription(description)
+	if key == "" {
+		return expense.Category{}, false
+	}
+
+	var suggestion expense.Category
+	var suggestionDate time.Time
+	for _, individualExpense := range history {
+		if !individualExpense.DeletedAt.IsZero() || individualExpense.Payment || individualExpense.Category.ID == 0 {
+			continue
+		}
+		if normalizeDescription(individualExpense.Description) != key {
+			continue
+		}
+		if suggestion.ID == 0 || individualExpense.Date.After(suggestionDate) {
+			suggestion = individualExpense.Category
+			suggestionDate = individualExpense.Date
+		}
+	}
+	return suggestion, suggestion.ID != 0
+}
+
+/*normalizeDescription - lower case words without the reference numbers banks add*/
+func normalizeDescription(description string) string {
+	words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return strings.Join(words, " ")
+}
+
+/*EqualSplit - expense shares where payerID paid amount and all members owe an equal part*/
+func EqualSplit(amount expense.Money, payerID int, members []expense.Members) []expense.ExpenseShare {
+	parts := amount.Split(len(members))
+	shares := make([]expense.ExpenseShare, 0, len(members))
+	for index, member := range members {
+		paid := expense.Money{Currency: amount.Currency}
+		if member.ID == payerID {
+			paid = amount
+		}
+		shares = append(shares, expense.ExpenseShare{
+			UserID:    member.ID,
+			PaidShare: paid.String(),
+			OwedShare: parts[index].String(),
+		})
+	}
+	return shares
+}
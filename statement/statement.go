@@ -0,0 +1,187 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+//sign conventions of statement amounts
+const (
+	//DebitsNegative - spending is negative and refunds are positive, as in most bank account exports
+	DebitsNegative = "debits_negative"
+	//DebitsPositive - spending is positive and refunds are negative, as in most credit card exports
+	DebitsPositive = "debits_positive"
+)
+
+/*Mapping - which csv columns hold the date, description and amount. Columns are header names, or zero based indexes when the file has no header*/
+type Mapping struct {
+	Date        string `json:"date"`
+	Description string `json:"description"`
+	Amount      string `json:"amount"`
+	//DateFormat - go reference layout, 2006-01-02 when empty
+	DateFormat string `json:"date_format"`
+	HasHeader  bool   `json:"has_header"`
+	//Sign - DebitsNegative or DebitsPositive, when empty every line is taken as spending
+	Sign string `json:"sign"`
+	//SkipCredits - leave refunds and other credits out instead of flagging them
+	SkipCredits bool `json:"skip_credits"`
+}
+
+/*Row - one parsed statement line, Error is set when the line could not be parsed*/
+type Row struct {
+	Line        int           `json:"line"`
+	Date        time.Time     `json:"date"`
+	Description string        `json:"description"`
+	Amount      expense.Money `json:"amount"`
+	//Credit - money coming in according to the mapping's Sign, e.g. a refund, Amount is still positive
+	Credit bool   `json:"credit,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+/*Parse - statement lines in currency. Amounts are positive, whether a line is a credit depends on the mapping's Sign since banks differ in the sign they give to card payments*/
+func Parse(reader io.Reader, mapping Mapping, currency string) ([]Row, error) {
+	if mapping.Sign != "" && mapping.Sign != DebitsNegative && mapping.Sign != DebitsPositive {
+		return nil, errors.Errorf("sign must be %s or %s", DebitsNegative, DebitsPositive)
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading csv")
+	}
+	if len(records) == 0 {
+		return nil, errors.New("csv is empty")
+	}
+
+	var header []string
+	firstLine := 1
+	if mapping.HasHeader {
+		header = records[0]
+		records = records[1:]
+		firstLine = 2
+	}
+
+	dateColumn, err := columnIndex(mapping.Date, header)
+	if err != nil {
+		return nil, errors.Wrap(err, "date column")
+	}
+	descriptionColumn, err := columnIndex(mapping.Description, header)
+	if err != nil {
+		return nil, errors.Wrap(err, "description column")
+	}
+	amountColumn, err := columnIndex(mapping.Amount, header)
+	if err != nil {
+		return nil, errors.Wrap(err, "amount column")
+	}
+
+	dateFormat := mapping.DateFormat
+	if dateFormat == "" {
+		dateFormat = "2006-01-02"
+	}
+
+	rows := make([]Row, 0, len(records))
+	for index, record := range records {
+		if blank(record) {
+			continue
+		}
+		row := Row{Line: firstLine + index}
+		rows = append(rows, row)
+		current := &rows[len(rows)-1]
+
+		maxColumn := dateColumn
+		if descriptionColumn > maxColumn {
+			maxColumn = descriptionColumn
+		}
+		if amountColumn > maxColumn {
+			maxColumn = amountColumn
+		}
+		if len(record) <= maxColumn {
+			current.Error = "missing columns"
+			continue
+		}
+
+		current.Description = strings.TrimSpace(record[descriptionColumn])
+		current.Date, err = time.Parse(dateFormat, strings.TrimSpace(record[dateColumn]))
+		if err != nil {
+			current.Error = "invalid date " + strconv.Quote(record[dateColumn])
+			continue
+		}
+		amount, err := parseAmount(record[amountColumn], currency)
+		if err != nil {
+			current.Error = err.Error()
+			continue
+		}
+		if amount.IsZero() {
+			current.Error = "amount is zero"
+			continue
+		}
+		current.Credit = mapping.Sign == DebitsNegative && amount.Minor > 0 || mapping.Sign == DebitsPositive && amount.Minor < 0
+		if amount.Minor < 0 {
+			amount = amount.Neg()
+		}
+		current.Amount = amount
+		if current.Credit && mapping.SkipCredits {
+			rows = rows[:len(rows)-1]
+		}
+	}
+	return rows, nil
+}
+
+func blank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+/*columnIndex - position of column by header name or index*/
+func columnIndex(column string, header []string) (int, error) {
+	if column == "" {
+		return 0, errors.New("is not mapped")
+	}
+	for index, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(column)) {
+			return index, nil
+		}
+	}
+	index, err := strconv.Atoi(column)
+	if err != nil || index < 0 {
+		return 0, errors.Errorf("%q not found", column)
+	}
+	return index, nil
+}
+
+var amountNoise = regexp.MustCompile(`[^0-9.\-()]`)
+
+/*parseAmount - bank amounts like "$1,234.56", "-12.30" or "(12.30)", the last two negative*/
+func parseAmount(amount string, currency string) (expense.Money, error) {
+	cleaned := amountNoise.ReplaceAllString(amount, "")
+	negative := strings.HasPrefix(cleaned, "(") && strings.HasSuffix(cleaned, ")")
+	cleaned = strings.Trim(cleaned, "()")
+	if strings.HasPrefix(cleaned, "-") {
+		negative = !negative
+		cleaned = strings.TrimPrefix(cleaned, "-")
+	}
+
+	money, err := expense.ParseMoney(cleaned, currency)
+	if err != nil || cleaned == "" {
+		return expense.Money{}, errors.Errorf("invalid amount %q", amount)
+	}
+	if negative {
+		money = money.Neg()
+	}
+	return money, nil
+}
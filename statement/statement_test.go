@@ -0,0 +1,68 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+)
+
+const bankCSV = `Date,Description,Amount
+2019-05-02,GROCERY STORE,-45.10
+2019-05-03,REFUND GROCERY STORE,12.00
+2019-05-04,PHARMACY,(7.25)
+2019-05-05,CASHBACK,"$1,000.00"
+`
+
+func TestParseSign(t *testing.T) {
+	tests := []struct {
+		sign        string
+		skipCredits bool
+		credits     []bool
+	}{
+		{"", false, []bool{false, false, false, false}},
+		{DebitsNegative, false, []bool{false, true, false, true}},
+		{DebitsPositive, false, []bool{true, false, true, false}},
+		{DebitsNegative, true, []bool{false, false}},
+	}
+	for _, test := range tests {
+		mapping := Mapping{Date: "Date", Description: "Description", Amount: "Amount", HasHeader: true, Sign: test.sign, SkipCredits: test.skipCredits}
+		rows, err := Parse(strings.NewReader(bankCSV), mapping, "USD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != len(test.credits) {
+			t.Errorf("sign %q skip %v: %d rows, want %d", test.sign, test.skipCredits, len(rows), len(test.credits))
+			continue
+		}
+		for index, row := range rows {
+			if row.Error != "" || row.Amount.Minor <= 0 {
+				t.Errorf("sign %q: line %d = %+v, want a positive amount", test.sign, row.Line, row)
+			}
+			if row.Credit != test.credits[index] {
+				t.Errorf("sign %q skip %v: line %d credit = %v", test.sign, test.skipCredits, row.Line, row.Credit)
+			}
+		}
+	}
+}
+
+func TestParseUnknownSign(t *testing.T) {
+	mapping := Mapping{Date: "0", Description: "1", Amount: "2", Sign: "negative"}
+	if _, err := Parse(strings.NewReader("2019-05-02,GROCERY STORE,-45.10\n"), mapping, "USD"); err == nil {
+		t.Error("unknown sign convention was accepted")
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := map[string]int64{
+		"12.30":     1230,
+		"-12.30":    -1230,
+		"(12.30)":   -1230,
+		"$1,234.56": 123456,
+		"-$5":       -500,
+	}
+	for amount, minor := range tests {
+		money, err := parseAmount(amount, "USD")
+		if err != nil || money.Minor != minor {
+			t.Errorf("parseAmount(%q) = %d, %v, want %d", amount, money.Minor, err, minor)
+		}
+	}
+}
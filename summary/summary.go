@@ -0,0 +1,146 @@
+package summary
+
+import (
+	"sort"
+	"strconv"
+
+	"splitwiseAngularAPI/expense"
+
+	"github.com/pkg/errors"
+)
+
+//dimensions expenses can be grouped by
+const (
+	ByCategory = "category"
+	ByMember   = "member"
+	ByMonth    = "month"
+)
+
+//Dimensions - all supported dimensions in response order
+var Dimensions = []string{ByCategory, ByMember, ByMonth}
+
+/*Bucket - aggregate of the expenses sharing a key, amounts are per currency*/
+type Bucket struct {
+	Key          string        `json:"key"`
+	Label        string        `json:"label"`
+	CurrencyCode string        `json:"currency_code"`
+	Total        expense.Money `json:"total"`
+	Count        int           `json:"count"`
+	Average      expense.Money `json:"average"`
+	Share        float64       `json:"share"`
+}
+
+/*Summary - buckets per requested dimension*/
+type Summary map[string][]Bucket
+
+/*Summarize - aggregate non deleted expenses by each dimension, member totals are owed shares, payments settle debts and are not spending*/
+func Summarize(expenses []expense.Expense, dimensions []string, memberNames map[int]string) (Summary, error) {
+	for _, dimension := range dimensions {
+		if !ValidDimension(dimension) {
+			return nil, errors.Errorf("unknown dimension %q", dimension)
+		}
+	}
+
+	summary := make(Summary)
+	for _, dimension := range dimensions {
+		buckets := make(map[string]*Bucket)
+		currencyTotals := make(map[string]expense.Money)
+
+		for _, individualExpense := range expenses {
+			if individualExpense.Payment || !individualExpense.DeletedAt.IsZero() {
+				continue
+			}
+
+			entries, err := entriesFor(dimension, individualExpense, memberNames)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				bucketKey := entry.key + "|" + individualExpense.CurrencyCode
+				bucket, ok := buckets[bucketKey]
+				if !ok {
+					bucket = &Bucket{Key: entry.key, Label: entry.label, CurrencyCode: individualExpense.CurrencyCode}
+					bucket.Total.Currency = individualExpense.CurrencyCode
+					buckets[bucketKey] = bucket
+				}
+				bucket.Total, _ = bucket.Total.Add(entry.amount)
+				bucket.Count++
+				currencyTotals[individualExpense.CurrencyCode], _ = currencyTotals[individualExpense.CurrencyCode].Add(entry.amount)
+			}
+		}
+
+		summary[dimension] = finish(buckets, currencyTotals)
+	}
+	return summary, nil
+}
+
+/*ValidDimension - whether expenses can be grouped by dimension*/
+func ValidDimension(dimension string) bool {
+	for _, known := range Dimensions {
+		if dimension == known {
+			return true
+		}
+	}
+	return false
+}
+
+type entry struct {
+	key    string
+	label  string
+	amount expense.Money
+}
+
+/*entriesFor - the amounts one expense contributes to buckets of dimension*/
+func entriesFor(dimension string, individualExpense expense.Expense, memberNames map[int]string) ([]entry, error) {
+	cost := expense.Money{Currency: individualExpense.CurrencyCode}
+	shares := make([]entry, 0, len(individualExpense.Users))
+	for _, userInfo := range individualExpense.Users {
+		owed, err := userInfo.Owed(individualExpense.CurrencyCode)
+		if err != nil {
+			return nil, errors.Wrapf(err, "expense %d", individualExpense.ID)
+		}
+		cost, _ = cost.Add(owed)
+
+		userID := strconv.Itoa(userInfo.UserID)
+		label, ok := memberNames[userInfo.UserID]
+		if !ok {
+			label = userID
+		}
+		if !owed.IsZero() {
+			shares = append(shares, entry{key: userID, label: label, amount: owed})
+		}
+	}
+
+	switch dimension {
+	case ByCategory:
+		return []entry{{key: strconv.Itoa(individualExpense.Category.ID), label: individualExpense.Category.Name, amount: cost}}, nil
+	case ByMonth:
+		month := individualExpense.Date.Format("2006-01")
+		return []entry{{key: month, label: individualExpense.Date.Format("January 2006"), amount: cost}}, nil
+	default:
+		return shares, nil
+	}
+}
+
+/*finish - compute averages and shares and order buckets by currency then total*/
+func finish(buckets map[string]*Bucket, currencyTotals map[string]expense.Money) []Bucket {
+	result := make([]Bucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucket.Average = bucket.Total.Divide(bucket.Count)
+		if total := currencyTotals[bucket.CurrencyCode]; !total.IsZero() {
+			bucket.Share = float64(bucket.Total.Minor) / float64(total.Minor)
+		}
+		result = append(result, *bucket)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CurrencyCode != result[j].CurrencyCode {
+			return result[i].CurrencyCode < result[j].CurrencyCode
+		}
+		if result[i].Total.Minor != result[j].Total.Minor {
+			return result[i].Total.Minor > result[j].Total.Minor
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
@@ -0,0 +1,41 @@
+package summary
+
+import (
+	"testing"
+	"time"
+
+	"splitwiseAngularAPI/expense"
+)
+
+func TestSummarizeSkipsPayments(t *testing.T) {
+	date := time.Date(2019, 5, 10, 0, 0, 0, 0, time.UTC)
+	groceries := expense.Category{ID: 12, Name: "Groceries"}
+	expenses := []expense.Expense{
+		{ID: 1, CurrencyCode: "USD", Date: date, Category: groceries, Users: []expense.UserInfo{
+			{UserID: 1, PaidShare: "30.00", OwedShare: "15.00"},
+			{UserID: 2, OwedShare: "15.00"},
+		}},
+		//bob pays alice back, nobody spent anything
+		{ID: 2, CurrencyCode: "USD", Date: date, Payment: true, Users: []expense.UserInfo{
+			{UserID: 2, PaidShare: "15.00"},
+			{UserID: 1, OwedShare: "15.00"},
+		}},
+	}
+
+	summary, err := Summarize(expenses, Dimensions, map[int]string{1: "Alice", 2: "Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dimension := range []string{ByCategory, ByMonth} {
+		buckets := summary[dimension]
+		if len(buckets) != 1 || buckets[0].Total.Minor != 3000 || buckets[0].Count != 1 {
+			t.Errorf("%s buckets = %+v, want only the 30.00 of groceries", dimension, buckets)
+		}
+	}
+	for _, bucket := range summary[ByMember] {
+		if bucket.Total.Minor != 1500 {
+			t.Errorf("%s spent %d cents, want 1500", bucket.Label, bucket.Total.Minor)
+		}
+	}
+}